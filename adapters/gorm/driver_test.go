@@ -0,0 +1,123 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+)
+
+// fakeConnector opens connections answering every query with no rows and
+// every exec with 3 affected rows, failing for queries containing "fail"
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query: query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if strings.Contains(query, "fail") {
+		return nil, errors.New("exec failed")
+	}
+	return driver.RowsAffected(3), nil
+}
+
+type fakeStmt struct {
+	query string
+}
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (fakeStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error)  { return fakeRows{}, nil }
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{"id"} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+func TestWrapConnector_Exec(t *testing.T) {
+	ctx, logFile := newCtx(t, common.DebugLevel)
+	db := sql.OpenDB(WrapConnector(fakeConnector{}))
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "UPDATE users SET name = ? WHERE id = ?", "bob", 7); err != nil {
+		t.Fatalf("ExecContext() error = %v", err)
+	}
+	if _, err := db.ExecContext(ctx, "fail"); err == nil {
+		t.Fatal("ExecContext() should return the driver error")
+	}
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %v", len(lines), lines)
+	}
+	for _, want := range []string{`"trace_id":"sql-trace"`, `"sql":"UPDATE users SET name = ? WHERE id = ?"`,
+		`"sql_args":["bob",7]`, `"rows_affected":3`, `"level":"[debug]"`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("log should contain %s, got %q", want, lines[0])
+		}
+	}
+	if !strings.Contains(lines[1], `"level":"[error]"`) || !strings.Contains(lines[1], `"error":{"msg":"exec failed"`) {
+		t.Errorf("failed exec should be logged as error, got %q", lines[1])
+	}
+}
+
+func TestWrapConnector_PreparedQuery(t *testing.T) {
+	ctx, logFile := newCtx(t, common.DebugLevel)
+	db := sql.OpenDB(WrapConnector(fakeConnector{}, WithRedactParams()))
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT id FROM users WHERE email = ?", "a@example.com")
+	if err != nil {
+		t.Fatalf("QueryContext() error = %v", err)
+	}
+	rows.Close()
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %v", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], `"sql":"SELECT id FROM users WHERE email = ?"`) || !strings.Contains(lines[0], "sql-trace") {
+		t.Errorf("prepared query should be logged with the ctx fields, got %q", lines[0])
+	}
+	if strings.Contains(lines[0], "a@example.com") || strings.Contains(lines[0], KeyRows) {
+		t.Errorf("redacted query should not log args or rows, got %q", lines[0])
+	}
+}
+
+func TestWrapDriver(t *testing.T) {
+	sql.Register("glog-fake", WrapDriver(fakeDriver{}))
+	ctx, logFile := newCtx(t, common.DebugLevel)
+
+	db, err := sql.Open("glog-fake", "")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "DELETE FROM sessions"); err != nil {
+		t.Fatalf("ExecContext() error = %v", err)
+	}
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"rows_affected":3`) {
+		t.Errorf("exec through a registered driver should be logged, got %v", lines)
+	}
+}
@@ -0,0 +1,113 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gw123/glog"
+	"gorm.io/gorm/logger"
+)
+
+// Logger implements GORM's logger.Interface through the glog context logger.
+// Failed queries are logged at error level, slow queries at warn level and
+// other queries at Options.QueryLevel, gated by the GORM log mode.
+type Logger struct {
+	options  Options
+	logLevel logger.LogLevel
+}
+
+var _ logger.Interface = (*Logger)(nil)
+
+// New returns a GORM logger with log mode logger.Info
+func New(withFuncs ...WithFunc) *Logger {
+	return &Logger{
+		options:  newOptions(withFuncs),
+		logLevel: logger.Info,
+	}
+}
+
+// LogMode returns a copy of the logger using level as GORM log mode
+func (l *Logger) LogMode(level logger.LogLevel) logger.Interface {
+	newLogger := *l
+	newLogger.logLevel = level
+	return &newLogger
+}
+
+func (l *Logger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.logLevel >= logger.Info {
+		glog.ExtractEntry(ctx).WithField(KeySource, querySource()).Infof(msg, data...)
+	}
+}
+
+func (l *Logger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.logLevel >= logger.Warn {
+		glog.ExtractEntry(ctx).WithField(KeySource, querySource()).Warnf(msg, data...)
+	}
+}
+
+func (l *Logger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.logLevel >= logger.Error {
+		glog.ExtractEntry(ctx).WithField(KeySource, querySource()).Errorf(msg, data...)
+	}
+}
+
+// Trace logs a finished query
+func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.logLevel <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	if err != nil && l.options.IgnoreRecordNotFound && errors.Is(err, logger.ErrRecordNotFound) {
+		err = nil
+	}
+
+	switch {
+	case err != nil && l.logLevel >= logger.Error:
+	case l.options.isSlow(elapsed) && l.logLevel >= logger.Warn:
+	case l.logLevel >= logger.Info:
+	default:
+		return
+	}
+
+	sql, rows := fc()
+	fields := map[string]interface{}{KeySource: querySource()}
+	l.options.logQuery(ctx, sql, nil, rows, elapsed, err, fields)
+}
+
+// ParamsFilter drops the query parameters when redaction is enabled, so GORM
+// renders the SQL with placeholders
+func (l *Logger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.options.RedactParams {
+		return sql, nil
+	}
+	return sql, params
+}
+
+var sourceDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file) + "/"
+}()
+
+// querySource returns the file:line of the first caller outside GORM and
+// this package, like GORM's own loggers report
+func querySource() string {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		inPackage := strings.HasPrefix(frame.File, sourceDir) && !strings.HasSuffix(frame.File, "_test.go")
+		if !inPackage && !strings.HasPrefix(frame.Function, "gorm.io/") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
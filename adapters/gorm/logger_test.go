@@ -0,0 +1,122 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gw123/glog"
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+	"gorm.io/gorm/logger"
+)
+
+func newCtx(t *testing.T, level common.Level) (context.Context, string) {
+	l, logFile := logtest.NewFileLogger(t, common.WithLevel(level))
+	ctx := glog.ToContext(context.Background(), l)
+	glog.AddTraceID(ctx, "sql-trace")
+	return ctx, logFile
+}
+
+func TestLogger_Trace(t *testing.T) {
+	ctx, logFile := newCtx(t, common.DebugLevel)
+	l := New()
+
+	l.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT * FROM users WHERE id = 1", 1 }, nil)
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %v", len(lines), lines)
+	}
+	for _, want := range []string{`"level":"[debug]"`, `"trace_id":"sql-trace"`, `"sql":"SELECT * FROM users WHERE id = 1"`,
+		`"rows_affected":1`, `"latency_ms":`, `"source":"`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("log should contain %s, got %q", want, lines[0])
+		}
+	}
+}
+
+func TestLogger_TraceLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []WithFunc
+		mode    logger.LogLevel
+		begin   time.Time
+		err     error
+		want    string
+	}{
+		{name: "error", mode: logger.Info, begin: time.Now(), err: errors.New("boom"), want: `"level":"[error]"`},
+		{name: "slow", mode: logger.Info, begin: time.Now().Add(-time.Second), want: `"slow_query":true`},
+		{name: "slow in warn mode", mode: logger.Warn, begin: time.Now().Add(-time.Second), want: `"level":"[warn]"`},
+		{name: "query in warn mode", mode: logger.Warn, begin: time.Now()},
+		{name: "error in silent mode", mode: logger.Silent, begin: time.Now(), err: errors.New("boom")},
+		{name: "not found ignored", options: []WithFunc{WithIgnoreRecordNotFound()}, mode: logger.Warn,
+			begin: time.Now(), err: logger.ErrRecordNotFound},
+		{name: "not found", mode: logger.Warn, begin: time.Now(), err: logger.ErrRecordNotFound, want: `"error":{"msg":"record not found"`},
+		{name: "info level", options: []WithFunc{WithQueryLevel(common.InfoLevel)}, mode: logger.Info,
+			begin: time.Now(), want: `"level":"[info]"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, logFile := newCtx(t, common.DebugLevel)
+			l := New(tt.options...).LogMode(tt.mode)
+
+			l.Trace(ctx, tt.begin, func() (string, int64) { return "SELECT 1", -1 }, tt.err)
+
+			lines := logtest.ReadLines(t, logFile)
+			if tt.want == "" {
+				if len(lines) != 0 {
+					t.Errorf("nothing should be logged, got %v", lines)
+				}
+				return
+			}
+			if len(lines) != 1 || !strings.Contains(lines[0], tt.want) {
+				t.Errorf("log should contain %s, got %v", tt.want, lines)
+			}
+			if strings.Contains(lines[0], KeyRows) {
+				t.Errorf("rows_affected should be omitted for -1, got %q", lines[0])
+			}
+		})
+	}
+}
+
+func TestLogger_ParamsFilter(t *testing.T) {
+	sql, params := New().ParamsFilter(context.Background(), "SELECT ?", "secret")
+	if sql != "SELECT ?" || len(params) != 1 {
+		t.Errorf("ParamsFilter() = %q, %v, want the params unchanged", sql, params)
+	}
+
+	sql, params = New(WithRedactParams()).ParamsFilter(context.Background(), "SELECT ?", "secret")
+	if sql != "SELECT ?" || params != nil {
+		t.Errorf("ParamsFilter() = %q, %v, want the params dropped", sql, params)
+	}
+}
+
+func TestLogger_Info(t *testing.T) {
+	ctx, logFile := newCtx(t, common.DebugLevel)
+
+	New().Info(ctx, "migrating %s", "users")
+	New().LogMode(logger.Warn).Info(ctx, "dropped")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"msg":"migrating users"`) || !strings.Contains(lines[0], "sql-trace") {
+		t.Errorf("Info() should log through the ctx logger, got %v", lines)
+	}
+}
+
+func TestLogger_Source(t *testing.T) {
+	ctx, logFile := newCtx(t, common.DebugLevel)
+
+	_, _, line, _ := runtime.Caller(0)
+	New().Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 1", 1 }, nil)
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], "logger_test.go:"+strconv.Itoa(line+1)) {
+		t.Errorf("source should be the caller outside GORM, got %v", lines)
+	}
+}
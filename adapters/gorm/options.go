@@ -0,0 +1,132 @@
+// Package gorm logs SQL queries through the glog context logger, both as a
+// GORM logger.Interface and as a database/sql driver wrapper, so queries
+// carry the trace_id of the request that issued them.
+package gorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/gw123/glog"
+	"github.com/gw123/glog/common"
+)
+
+const (
+	DefaultSlowThreshold = 200 * time.Millisecond
+
+	KeySQL       = "sql"
+	KeyArgs      = "sql_args"
+	KeyRows      = "rows_affected"
+	KeyLatencyMs = "latency_ms"
+	KeySlow      = "slow_query"
+	KeySource    = "source"
+
+	QueryLogMsg = "sql query"
+)
+
+type Options struct {
+	// SlowThreshold queries slower than this are logged at warn level, zero
+	// uses DefaultSlowThreshold and a negative value disables slow logging
+	SlowThreshold time.Duration
+	// RedactParams keeps placeholders in the logged SQL instead of values
+	RedactParams bool
+	// IgnoreRecordNotFound does not treat gorm.ErrRecordNotFound as an error
+	IgnoreRecordNotFound bool
+	// QueryLevel is the level of queries that are neither slow nor failed
+	QueryLevel common.Level
+}
+
+type WithFunc func(o *Options)
+
+// WithSlowThreshold sets the duration above which queries are logged as slow
+func WithSlowThreshold(threshold time.Duration) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.SlowThreshold = threshold
+	}
+}
+
+// WithRedactParams logs SQL with placeholders instead of parameter values
+func WithRedactParams() WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.RedactParams = true
+	}
+}
+
+// WithIgnoreRecordNotFound does not log gorm.ErrRecordNotFound as an error
+func WithIgnoreRecordNotFound() WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.IgnoreRecordNotFound = true
+	}
+}
+
+// WithQueryLevel sets the level of queries that are neither slow nor failed
+func WithQueryLevel(level common.Level) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.QueryLevel = level
+	}
+}
+
+func newOptions(withFuncs []WithFunc) Options {
+	options := Options{QueryLevel: common.DebugLevel}
+	for _, withFunc := range withFuncs {
+		withFunc(&options)
+	}
+	if options.SlowThreshold == 0 {
+		options.SlowThreshold = DefaultSlowThreshold
+	}
+	return options
+}
+
+func (o Options) isSlow(elapsed time.Duration) bool {
+	return o.SlowThreshold > 0 && elapsed > o.SlowThreshold
+}
+
+// logQuery writes one query entry through the context logger. rows below
+// zero are omitted.
+func (o Options) logQuery(ctx context.Context, query string, args []interface{}, rows int64, elapsed time.Duration, err error, fields map[string]interface{}) {
+	if fields == nil {
+		fields = make(map[string]interface{}, 5)
+	}
+	fields[KeySQL] = query
+	fields[KeyLatencyMs] = float64(elapsed.Microseconds()) / 1000
+	if rows >= 0 {
+		fields[KeyRows] = rows
+	}
+	if len(args) > 0 && !o.RedactParams {
+		fields[KeyArgs] = args
+	}
+
+	level := o.QueryLevel
+	if o.isSlow(elapsed) {
+		fields[KeySlow] = true
+		level = common.WarnLevel
+	}
+	logger := glog.ExtractEntry(ctx).WithFields(fields)
+	if err != nil {
+		logger.WithError(err).Error(QueryLogMsg)
+		return
+	}
+
+	switch {
+	case level <= common.DebugLevel:
+		logger.Debug(QueryLogMsg)
+	case level == common.InfoLevel:
+		logger.Info(QueryLogMsg)
+	case level == common.WarnLevel:
+		logger.Warn(QueryLogMsg)
+	default:
+		logger.Error(QueryLogMsg)
+	}
+}
@@ -0,0 +1,104 @@
+// Package logr exposes glog as a go-logr/logr LogSink, so libraries built on
+// logr such as controller-runtime share the glog output format.
+package logr
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogSink implements logr.LogSink over a common.Logger. V(0) entries are
+// logged at info level and V(1) and above at debug level.
+type LogSink struct {
+	logger common.Logger
+}
+
+var (
+	_ logr.LogSink          = (*LogSink)(nil)
+	_ logr.CallDepthLogSink = (*LogSink)(nil)
+)
+
+// NewLogSink returns a logr.LogSink writing through logger
+func NewLogSink(logger common.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+// NewLogger returns a logr.Logger writing through logger
+func NewLogger(logger common.Logger) logr.Logger {
+	return logr.New(NewLogSink(logger))
+}
+
+// Init skips the logr.Logger frames and this sink when reporting the caller
+func (s *LogSink) Init(info logr.RuntimeInfo) {
+	s.logger = common.AddCallerSkip(s.logger, info.CallDepth+1)
+}
+
+// Enabled reports whether entries at the V-level are written
+func (s *LogSink) Enabled(level int) bool {
+	d, ok := s.logger.(interface{ Desugar() *zap.Logger })
+	if !ok {
+		return true
+	}
+	return d.Desugar().Core().Enabled(toZapLevel(level))
+}
+
+// Info logs a non-error message at the glog level mapped from the V-level
+func (s *LogSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	logger := s.withValues(keysAndValues)
+	if level > 0 {
+		logger.Debug(msg)
+		return
+	}
+	logger.Info(msg)
+}
+
+// Error logs err and msg at error level
+func (s *LogSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.withValues(keysAndValues).WithError(err).Error(msg)
+}
+
+// WithValues returns a sink adding keysAndValues to every entry
+func (s *LogSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &LogSink{logger: s.withValues(keysAndValues)}
+}
+
+// WithName returns a sink with name appended to the logger name
+func (s *LogSink) WithName(name string) logr.LogSink {
+	return &LogSink{logger: s.logger.Named(name)}
+}
+
+// WithCallDepth returns a sink skipping depth more frames for the caller
+func (s *LogSink) WithCallDepth(depth int) logr.LogSink {
+	return &LogSink{logger: common.AddCallerSkip(s.logger, depth)}
+}
+
+func (s *LogSink) withValues(keysAndValues []interface{}) common.Logger {
+	if len(keysAndValues) == 0 {
+		return s.logger
+	}
+
+	fields := make(map[string]interface{}, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		if i+1 < len(keysAndValues) {
+			fields[key] = keysAndValues[i+1]
+		} else {
+			fields[key] = "<no-value>"
+		}
+	}
+	return s.logger.WithFields(fields)
+}
+
+func toZapLevel(level int) zapcore.Level {
+	if level > 0 {
+		return zapcore.DebugLevel
+	}
+	return zapcore.InfoLevel
+}
@@ -0,0 +1,87 @@
+package logr
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+)
+
+func TestLogSink_InfoAndValues(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	log := NewLogger(logger).WithName("controller").WithValues("reconciler", "pods")
+
+	log.Info("reconciled", "namespace", "default", "dangling")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %v", len(lines), lines)
+	}
+	for _, want := range []string{`"level":"[info]"`, `"msg":"reconciled"`, `"reconciler":"pods"`,
+		`"namespace":"default"`, `"dangling":"<no-value>"`, "controller", "sink_test.go"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("log should contain %s, got %q", want, lines[0])
+		}
+	}
+}
+
+func TestLogSink_VLevels(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	log := NewLogger(logger)
+
+	if !log.V(0).Enabled() {
+		t.Error("V(0) should be enabled for an info logger")
+	}
+	if log.V(1).Enabled() {
+		t.Error("V(1) should be disabled for an info logger")
+	}
+	log.V(1).Info("verbose")
+	log.V(0).Info("normal")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], "normal") {
+		t.Errorf("only the V(0) entry should be written, got %v", lines)
+	}
+
+	debugLogger, debugFile := logtest.NewFileLogger(t, common.WithLevel(common.DebugLevel))
+	NewLogger(debugLogger).V(2).Info("very verbose")
+	lines = logtest.ReadLines(t, debugFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"level":"[debug]"`) {
+		t.Errorf("V(2) should be logged at debug level, got %v", lines)
+	}
+}
+
+func TestLogSink_Error(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	NewLogger(logger).Error(errors.New("boom"), "reconcile failed", "attempt", 3)
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %v", len(lines), lines)
+	}
+	for _, want := range []string{`"level":"[error]"`, `"error":{"msg":"boom"`, `"attempt":3`, "sink_test.go"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("log should contain %s, got %q", want, lines[0])
+		}
+	}
+}
+
+func TestLogSink_CallDepth(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	log := NewLogger(logger)
+
+	helper := func(msg string) {
+		log.WithCallDepth(1).Info(msg)
+	}
+	_, _, line, _ := runtime.Caller(0)
+	helper("from helper")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], "sink_test.go:"+strconv.Itoa(line+1)) {
+		t.Errorf("caller should be the helper call site, got %v", lines)
+	}
+}
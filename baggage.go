@@ -0,0 +1,36 @@
+package glog
+
+import (
+	"context"
+
+	"github.com/gw123/glog/common"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// extractBaggageFields returns the baggage members found in ctx that are
+// whitelisted by logger, see common.WithBaggageFields
+func extractBaggageFields(ctx context.Context, logger common.Logger) map[string]interface{} {
+	bagLogger, ok := logger.(common.BaggageLogger)
+	if !ok {
+		return nil
+	}
+	cfg := bagLogger.BaggageOptions()
+	if len(cfg.Keys) == 0 {
+		return nil
+	}
+
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 {
+		return nil
+	}
+
+	fields := make(map[string]interface{}, len(cfg.Keys))
+	for _, key := range cfg.Keys {
+		member := bag.Member(key)
+		if member.Key() == "" {
+			continue
+		}
+		fields[cfg.Prefix+key] = member.Value()
+	}
+	return fields
+}
@@ -0,0 +1,79 @@
+package glog
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func contextWithBaggage(t *testing.T, ctx context.Context, kv map[string]string) context.Context {
+	members := make([]baggage.Member, 0, len(kv))
+	for k, v := range kv {
+		m, err := baggage.NewMember(k, v)
+		if err != nil {
+			t.Fatalf("baggage.NewMember() error = %v", err)
+		}
+		members = append(members, m)
+	}
+	bag, err := baggage.New(members...)
+	if err != nil {
+		t.Fatalf("baggage.New() error = %v", err)
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+func TestExtractBaggageFields_Whitelist(t *testing.T) {
+	logger, _ := logtest.NewFileLogger(t, common.WithBaggageFields("bg_", "tenant", "flag"))
+
+	ctx := contextWithBaggage(t, context.Background(), map[string]string{
+		"tenant": "acme",
+		"secret": "s3cr3t",
+	})
+
+	fields := extractBaggageFields(ctx, logger)
+	if len(fields) != 1 {
+		t.Fatalf("extractBaggageFields() = %v, want exactly one field", fields)
+	}
+	if fields["bg_tenant"] != "acme" {
+		t.Errorf("bg_tenant = %v, want acme", fields["bg_tenant"])
+	}
+}
+
+func TestExtractBaggageFields_Disabled(t *testing.T) {
+	logger, _ := logtest.NewFileLogger(t)
+
+	ctx := contextWithBaggage(t, context.Background(), map[string]string{"tenant": "acme"})
+	if fields := extractBaggageFields(ctx, logger); fields != nil {
+		t.Errorf("extractBaggageFields() = %v, want nil when not configured", fields)
+	}
+}
+
+func TestExtractBaggageFields_NoBaggage(t *testing.T) {
+	logger, _ := logtest.NewFileLogger(t, common.WithBaggageFields("", "tenant"))
+
+	if fields := extractBaggageFields(context.Background(), logger); fields != nil {
+		t.Errorf("extractBaggageFields() = %v, want nil without baggage", fields)
+	}
+}
+
+func TestExtractEntry_WithBaggage(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithBaggageFields("baggage.", "tenant"))
+	other, otherFile := logtest.NewFileLogger(t, common.WithBaggageFields("", "region"))
+
+	bag := contextWithBaggage(t, context.Background(), map[string]string{"tenant": "acme", "region": "eu"})
+	ExtractEntry(ToContext(bag, logger.WithField("svc", "api"))).Info("baggage test")
+	ExtractEntry(ToContext(bag, other)).Info("other whitelist")
+
+	content := logtest.ReadLog(t, logFile)
+	if !strings.Contains(content, `"baggage.tenant":"acme"`) || strings.Contains(content, "region") {
+		t.Errorf("log should contain only the tenant baggage field, got %q", content)
+	}
+	content = logtest.ReadLog(t, otherFile)
+	if !strings.Contains(content, `"region":"eu"`) || strings.Contains(content, "tenant") {
+		t.Errorf("each logger should use its own whitelist, got %q", content)
+	}
+}
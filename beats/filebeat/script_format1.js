@@ -4,6 +4,11 @@
 // output 2020-12-29T18:15:23.847+0800
 //        2020-12-30T18:23:42.328+0800
 function formatTime(time){
+    // RFC 3339 times (common.WithTimestampFormat, the JSON default) already
+    // carry their timezone, the default console time is local and taken as +0800
+    if(/T.*(Z|[+-]\d\d:?\d\d)$/.test(time)){
+        return time
+    }
     var times = time.replace(/-/g,':').replace('.', ':' ).replace(' ',':').split(':')
     var resultTime = ""
     if(times.length != 7){
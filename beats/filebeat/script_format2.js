@@ -5,6 +5,11 @@
 // input  2020-12-28 19:28:42.325
 // output 2020-12-29T18:15:23.847+0800
 function formatTime(time){
+    // RFC 3339 times (common.WithTimestampFormat, the JSON default) already
+    // carry their timezone, the default console time is local and taken as +0800
+    if(/T.*(Z|[+-]\d\d:?\d\d)$/.test(time)){
+        return time
+    }
     var times = time.replace(/-/g,':').replace('.', ':' ).replace(' ',':').split(':')
     var resultTime = ""
     if(times.length != 7){
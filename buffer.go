@@ -0,0 +1,253 @@
+package glog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	DefaultBufferMaxEntries = 1000
+	DefaultBufferMaxBytes   = 1 << 20
+)
+
+// BufferOptions configures request scoped log buffering
+type BufferOptions struct {
+	// Level entries below this level are buffered instead of written
+	Level common.Level
+	// MaxEntries bounds the number of buffered entries, the oldest are dropped first
+	MaxEntries int
+	// MaxBytes bounds the approximate encoded size of buffered entries and their
+	// fields, the oldest are dropped first
+	MaxBytes int
+}
+
+// coreWrapper is implemented by loggers that allow wrapping their zapcore.Core
+type coreWrapper interface {
+	WrapCore(f func(zapcore.Core) zapcore.Core) common.Logger
+}
+
+type bufferedEntry struct {
+	core   zapcore.Core
+	entry  zapcore.Entry
+	fields []zapcore.Field
+	size   int
+}
+
+type logBuffer struct {
+	opts    BufferOptions
+	entries []bufferedEntry
+	size    int
+	dropped int
+	mutex   sync.Mutex
+}
+
+func newLogBuffer(opts BufferOptions) *logBuffer {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultBufferMaxEntries
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultBufferMaxBytes
+	}
+	return &logBuffer{opts: opts}
+}
+
+func (b *logBuffer) add(e bufferedEntry) {
+	b.mutex.Lock()
+	b.entries = append(b.entries, e)
+	b.size += e.size
+	for len(b.entries) > 0 && (len(b.entries) > b.opts.MaxEntries || b.size > b.opts.MaxBytes) {
+		b.size -= b.entries[0].size
+		b.entries[0] = bufferedEntry{}
+		b.entries = b.entries[1:]
+		b.dropped++
+	}
+	b.mutex.Unlock()
+}
+
+func (b *logBuffer) take() ([]bufferedEntry, int) {
+	b.mutex.Lock()
+	entries, dropped := b.entries, b.dropped
+	b.entries, b.size, b.dropped = nil, 0, 0
+	b.mutex.Unlock()
+	return entries, dropped
+}
+
+func (b *logBuffer) flush() {
+	entries, dropped := b.take()
+	if dropped > 0 && len(entries) > 0 {
+		first := entries[0]
+		first.core.Write(zapcore.Entry{
+			Level:      zapcore.WarnLevel,
+			Time:       time.Now(),
+			LoggerName: first.entry.LoggerName,
+			Message:    fmt.Sprintf("glog: dropped %d buffered log entries", dropped),
+		}, nil)
+	}
+	for _, e := range entries {
+		e.core.Write(e.entry, e.fields)
+	}
+}
+
+func (b *logBuffer) discard() {
+	b.take()
+}
+
+// bufferCore holds entries below the buffer level until an error level entry
+// is written or the buffer is flushed
+type bufferCore struct {
+	zapcore.Core
+	buffer *logBuffer
+	// withSize is the approximate size of the fields added with With
+	withSize int
+}
+
+func (c *bufferCore) Enabled(lvl zapcore.Level) bool {
+	return lvl < zapcore.Level(c.buffer.opts.Level) || c.Core.Enabled(lvl)
+}
+
+func (c *bufferCore) With(fields []zapcore.Field) zapcore.Core {
+	return &bufferCore{Core: c.Core.With(fields), buffer: c.buffer, withSize: c.withSize + fieldsSize(fields)}
+}
+
+// Check buffers entries below the buffer level whatever the level of the
+// wrapped core, and leaves the others to the wrapped core so sampling
+// applies to them
+func (c *bufferCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < zapcore.Level(c.buffer.opts.Level) {
+		return ce.AddCore(ent, c)
+	}
+	checked := c.Core.Check(ent, nil)
+	if checked == nil {
+		return ce
+	}
+	written := &checkedBufferCore{bufferCore: c, checked: checked}
+	ce = ce.AddCore(ent, written)
+	written.logged = ce
+	return ce
+}
+
+func (c *bufferCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level < zapcore.Level(c.buffer.opts.Level) {
+		c.buffer.add(bufferedEntry{
+			core:   c.Core,
+			entry:  ent,
+			fields: fields,
+			size:   c.withSize + entrySize(ent, fields),
+		})
+		return nil
+	}
+	if ent.Level >= zapcore.ErrorLevel {
+		c.buffer.flush()
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// checkedBufferCore writes an entry the wrapped core of a bufferCore checked
+// it for, flushing the buffer first when it is an error
+type checkedBufferCore struct {
+	*bufferCore
+	checked *zapcore.CheckedEntry
+	// logged is the entry of the logger checked was added to
+	logged *zapcore.CheckedEntry
+}
+
+func (c *checkedBufferCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level >= zapcore.ErrorLevel {
+		c.buffer.flush()
+	}
+	// The logger adds the caller and stack after checking
+	c.checked.Entry = ent
+	// Write reports the errors of the cores to the error output of the
+	// logger and returns checked to its pool
+	c.checked.ErrorOutput = c.logged.ErrorOutput
+	c.checked.Write(fields...)
+	return nil
+}
+
+// sizeEncoder measures the fields holding maps, structs, marshalers and
+// other values
+var sizeEncoder = zapcore.NewJSONEncoder(zapcore.EncoderConfig{})
+
+// entrySize approximates the encoded size of an entry
+func entrySize(ent zapcore.Entry, fields []zapcore.Field) int {
+	return len(ent.Message) + len(ent.LoggerName) + len(ent.Caller.File) + 64 + fieldsSize(fields)
+}
+
+// fieldsSize approximates the encoded size of fields
+func fieldsSize(fields []zapcore.Field) int {
+	size := 0
+	var values []zapcore.Field
+	for _, f := range fields {
+		if f.Interface != nil {
+			values = append(values, f)
+			continue
+		}
+		size += len(f.Key) + len(f.String) + 16
+	}
+	if len(values) > 0 {
+		if buf, err := sizeEncoder.EncodeEntry(zapcore.Entry{}, values); err == nil {
+			size += buf.Len()
+			buf.Free()
+		}
+	}
+	return size
+}
+
+// EnableBuffering turns on request scoped buffering for the context logger.
+// Entries logged through ExtractEntry below opts.Level are held in memory and
+// only written when an error level entry is logged or FlushCtx is called.
+// Buffered entries bypass the logger level, so debug entries can be kept
+// for failing requests while the logger itself runs at info level.
+func EnableBuffering(ctx context.Context, opts BufferOptions) {
+	l, ok := ctx.Value(ctxLoggerKey).(*ctxLogger)
+	if !ok || l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	l.buffer = newLogBuffer(opts)
+	l.mutex.Unlock()
+}
+
+// FlushCtx writes all buffered entries of the context logger in order
+func FlushCtx(ctx context.Context) {
+	if b := extractBuffer(ctx); b != nil {
+		b.flush()
+	}
+}
+
+// DiscardCtx drops all buffered entries of the context logger, usually
+// called when a request ends successfully
+func DiscardCtx(ctx context.Context) {
+	if b := extractBuffer(ctx); b != nil {
+		b.discard()
+	}
+}
+
+func extractBuffer(ctx context.Context) *logBuffer {
+	l, ok := ctx.Value(ctxLoggerKey).(*ctxLogger)
+	if !ok || l == nil {
+		return nil
+	}
+
+	l.mutex.RLock()
+	b := l.buffer
+	l.mutex.RUnlock()
+	return b
+}
+
+// withBuffer wraps logger so it writes through the buffer
+func withBuffer(logger common.Logger, b *logBuffer) common.Logger {
+	wrapper, ok := logger.(coreWrapper)
+	if !ok {
+		return logger
+	}
+	return wrapper.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &bufferCore{Core: core, buffer: b}
+	})
+}
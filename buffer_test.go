@@ -0,0 +1,149 @@
+package glog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+)
+
+func TestBuffering_FlushOnError(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+	EnableBuffering(ctx, BufferOptions{Level: common.WarnLevel})
+	AddTraceID(ctx, "buf-trace")
+
+	ExtractEntry(ctx).Debug("buffered debug")
+	ExtractEntry(ctx).Info("buffered info")
+	ExtractEntry(ctx).Warn("direct warn")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], "direct warn") {
+		t.Fatalf("only the warn entry should be written before an error, got %v", lines)
+	}
+
+	ExtractEntry(ctx).WithError(errors.New("boom")).Error("request failed")
+
+	lines = logtest.ReadLines(t, logFile)
+	want := []string{"direct warn", "buffered debug", "buffered info", "request failed"}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %v", len(lines), len(want), lines)
+	}
+	for i, msg := range want {
+		if !strings.Contains(lines[i], msg) {
+			t.Errorf("line %d = %q, want it to contain %q", i, lines[i], msg)
+		}
+		if !strings.Contains(lines[i], "buf-trace") {
+			t.Errorf("line %d = %q, should keep the trace_id", i, lines[i])
+		}
+	}
+}
+
+func TestBuffering_Discard(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+	EnableBuffering(ctx, BufferOptions{Level: common.WarnLevel})
+
+	ExtractEntry(ctx).Info("discarded info")
+	DiscardCtx(ctx)
+	ExtractEntry(ctx).Error("later error")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], "later error") {
+		t.Errorf("discarded entries should not be written, got %v", lines)
+	}
+}
+
+func TestBuffering_FlushCtx(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+	EnableBuffering(ctx, BufferOptions{Level: common.WarnLevel})
+
+	ExtractEntry(ctx).Info("flushed info")
+	FlushCtx(ctx)
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], "flushed info") {
+		t.Errorf("FlushCtx() should write buffered entries, got %v", lines)
+	}
+
+	FlushCtx(ctx)
+	if lines := logtest.ReadLines(t, logFile); len(lines) != 1 {
+		t.Errorf("a second FlushCtx() should not write again, got %v", lines)
+	}
+}
+
+func TestBuffering_MaxEntries(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+	EnableBuffering(ctx, BufferOptions{Level: common.WarnLevel, MaxEntries: 2})
+
+	ExtractEntry(ctx).Info("entry one")
+	ExtractEntry(ctx).Info("entry two")
+	ExtractEntry(ctx).Info("entry three")
+	FlushCtx(ctx)
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3: %v", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], "dropped 1 buffered log entries") {
+		t.Errorf("first line should report dropped entries, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "entry two") || !strings.Contains(lines[2], "entry three") {
+		t.Errorf("oldest entry should be dropped first, got %v", lines)
+	}
+}
+
+func TestBuffering_MaxBytes(t *testing.T) {
+	buf := newLogBuffer(BufferOptions{MaxBytes: 200})
+	for i := 0; i < 10; i++ {
+		buf.add(bufferedEntry{size: 50})
+	}
+	entries, dropped := buf.take()
+	if len(entries) != 4 || dropped != 6 {
+		t.Errorf("take() = %d entries, %d dropped, want 4 and 6", len(entries), dropped)
+	}
+}
+
+func TestBuffering_MaxBytesCountsValues(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+	EnableBuffering(ctx, BufferOptions{Level: common.WarnLevel, MaxBytes: 2000})
+
+	payload := map[string]interface{}{"body": strings.Repeat("x", 1000)}
+	for i := 0; i < 3; i++ {
+		ExtractEntry(ctx).WithField("payload", payload).Info("large entry")
+	}
+	FlushCtx(ctx)
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 2 || !strings.Contains(lines[0], "dropped 2 buffered log entries") {
+		t.Errorf("map values should count towards MaxBytes, got %d lines: %v", len(lines), lines)
+	}
+}
+
+func TestBuffering_KeepsSampling(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+	EnableBuffering(ctx, BufferOptions{Level: common.WarnLevel})
+
+	for i := 0; i < 150; i++ {
+		ExtractEntry(ctx).Warn("repeated warn")
+	}
+
+	// The logger keeps the first 100 entries with the same message per second
+	if lines := logtest.ReadLines(t, logFile); len(lines) != 100 {
+		t.Errorf("entries written through the buffer should be sampled, got %d lines", len(lines))
+	}
+}
+
+func TestBuffering_NoContextLogger(t *testing.T) {
+	ctx := context.Background()
+	EnableBuffering(ctx, BufferOptions{})
+	FlushCtx(ctx)
+	DiscardCtx(ctx)
+}
@@ -0,0 +1,55 @@
+// Command glog-decode converts logs written with the msgpack or cbor encoding
+// to JSON lines.
+//
+//	glog-decode -encoding cbor app.log > app.json
+//	nc -l 9000 | glog-decode -encoding msgpack
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/zap"
+)
+
+func main() {
+	encoding := flag.String("encoding", common.EncodeMsgpack, "encoding of the log, msgpack or cbor")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-encoding msgpack|cbor] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	out := bufio.NewWriter(os.Stdout)
+	err := decode(*encoding, flag.Args(), out)
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "glog-decode: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// decode converts the files, or stdin when there are none, to w
+func decode(encoding string, files []string, w io.Writer) error {
+	if len(files) == 0 {
+		return zap.DecodeBinaryLog(encoding, bufio.NewReader(os.Stdin), w)
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = zap.DecodeBinaryLog(encoding, bufio.NewReader(f), w)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package common
+
+// BaggageOptions selects the OpenTelemetry baggage members ExtractEntry copies
+// into the fields of a logger
+type BaggageOptions struct {
+	// Prefix is prepended to the member keys to build the field keys
+	Prefix string
+	// Keys are the whitelisted members, baggage is not logged when empty
+	Keys []string
+}
+
+// BaggageLogger is implemented by loggers configured with a baggage
+// whitelist
+type BaggageLogger interface {
+	BaggageOptions() BaggageOptions
+}
+
+// WithBaggageFields logs the baggage members keys found in the context as
+// prefix+key. Without keys baggage is not logged.
+func WithBaggageFields(prefix string, keys ...string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Baggage = BaggageOptions{Prefix: prefix, Keys: append([]string(nil), keys...)}
+	}
+}
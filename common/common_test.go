@@ -2,6 +2,7 @@ package common
 
 import (
 	"testing"
+	"time"
 )
 
 func TestLevel_String(t *testing.T) {
@@ -214,6 +215,28 @@ func TestOptions_WithFunctions(t *testing.T) {
 		t.Error("WithConsoleEncoding() failed")
 	}
 
+	keys := []string{"tenant"}
+	WithBaggageFields("bg_", keys...)(&opts)
+	keys[0] = "changed"
+	if opts.Baggage.Prefix != "bg_" || len(opts.Baggage.Keys) != 1 || opts.Baggage.Keys[0] != "tenant" {
+		t.Errorf("WithBaggageFields() = %+v", opts.Baggage)
+	}
+
+	WithGCPEncoding("my-project")(&opts)
+	if opts.Encoding != EncodeGCP || opts.GCPProjectID != "my-project" {
+		t.Error("WithGCPEncoding() failed")
+	}
+
+	WithMsgpackEncoding()(&opts)
+	if opts.Encoding != EncodeMsgpack {
+		t.Error("WithMsgpackEncoding() failed")
+	}
+
+	WithCBOREncoding()(&opts)
+	if opts.Encoding != EncodeCBOR {
+		t.Error("WithCBOREncoding() failed")
+	}
+
 	WithJsonEncoding()(&opts)
 	if opts.Encoding != EncodeJson {
 		t.Error("WithJsonEncoding() failed")
@@ -228,6 +251,45 @@ func TestOptions_WithFunctions(t *testing.T) {
 	if opts.CallerSkip != 2 {
 		t.Error("WithCallerSkip() failed")
 	}
+
+	WithStacktraceLevel(ErrorLevel)(&opts)
+	if !opts.Stacktrace || opts.StacktraceLevel != ErrorLevel {
+		t.Error("WithStacktraceLevel() failed")
+	}
+
+	WithStacktraceTrim()(&opts)
+	if !opts.TrimStacktrace {
+		t.Error("WithStacktraceTrim() failed")
+	}
+
+	WithConsoleLayout("{level} {msg}")(&opts)
+	if opts.ConsoleLayout != "{level} {msg}" {
+		t.Error("WithConsoleLayout() failed")
+	}
+
+	WithConsoleTimeFormat(TimeFormat)(&opts)
+	if opts.ConsoleTimeFormat != TimeFormat {
+		t.Error("WithConsoleTimeFormat() failed")
+	}
+
+	WithTimestampFormat(TimestampEpochMillis)(&opts)
+	if opts.TimestampFormat != TimestampEpochMillis {
+		t.Error("WithTimestampFormat() failed")
+	}
+
+	WithTimezone(time.UTC)(&opts)
+	if opts.Timezone != time.UTC {
+		t.Error("WithTimezone() failed")
+	}
+
+	WithJSONKeys(JSONKeys{Message: "message"})(&opts)
+	WithJSONFieldsKey("fields")(&opts)
+	WithJSONHoistKeys("request_id")(&opts)
+	WithJSONLevelCase(LevelUpper)(&opts)
+	if opts.JSON.Keys.Message != "message" || opts.JSON.FieldsKey != "fields" ||
+		len(opts.JSON.HoistKeys) != 1 || opts.JSON.LevelCase != LevelUpper {
+		t.Errorf("WithJSON*() failed, got %+v", opts.JSON)
+	}
 }
 
 func TestOptions_WithFunctions_NilOptions(t *testing.T) {
@@ -238,8 +300,19 @@ func TestOptions_WithFunctions_NilOptions(t *testing.T) {
 	WithOutputPath("test.log")(opts)
 	WithConsoleEncoding()(opts)
 	WithJsonEncoding()(opts)
+	WithGCPEncoding("my-project")(opts)
 	WithLevel(DebugLevel)(opts)
 	WithCallerSkip(1)(opts)
+	WithStacktraceLevel(ErrorLevel)(opts)
+	WithStacktraceTrim()(opts)
+	WithConsoleLayout("{msg}")(opts)
+	WithConsoleTimeFormat(TimeFormat)(opts)
+	WithTimestampFormat(TimestampRFC3339)(opts)
+	WithTimezone(time.UTC)(opts)
+	WithJSONKeys(JSONKeys{})(opts)
+	WithJSONFieldsKey("fields")(opts)
+	WithJSONHoistKeys()(opts)
+	WithJSONLevelCase(LevelLower)(opts)
 }
 
 func TestConstants(t *testing.T) {
@@ -275,6 +348,30 @@ func TestConstants(t *testing.T) {
 	}
 }
 
+func TestRegisterTopField(t *testing.T) {
+	RegisterTopField("tenant_id")
+	RegisterTopField("session_id")
+	RegisterTopField("tenant_id")
+	defer UnregisterTopField("tenant_id")
+	defer UnregisterTopField("session_id")
+
+	keys := TopFields()
+	if len(keys) != 2 || keys[0] != "tenant_id" || keys[1] != "session_id" {
+		t.Errorf("TopFields() = %v, want [tenant_id session_id]", keys)
+	}
+	if !IsTopField("tenant_id") {
+		t.Error("IsTopField(tenant_id) = false, want true")
+	}
+	if IsTopField("order_id") {
+		t.Error("IsTopField(order_id) = true, want false")
+	}
+
+	UnregisterTopField("tenant_id")
+	if IsTopField("tenant_id") {
+		t.Error("IsTopField(tenant_id) after UnregisterTopField() = true, want false")
+	}
+}
+
 func BenchmarkLevel_String(b *testing.B) {
 	l := InfoLevel
 	b.ResetTimer()
@@ -300,3 +397,20 @@ func BenchmarkLevel_UnmarshalText(b *testing.B) {
 		l.UnmarshalText(text)
 	}
 }
+
+func TestLimits_WithFunctions(t *testing.T) {
+	opts := Options{}
+	if opts.Limits.Enabled() {
+		t.Error("Limits should be disabled by default")
+	}
+
+	WithMaxMessageBytes(1)(&opts)
+	WithMaxStringBytes(2)(&opts)
+	WithMaxFields(3)(&opts)
+	WithMaxCollectionLen(4)(&opts)
+	WithMaxDepth(5)(&opts)
+	want := Limits{MaxMessageBytes: 1, MaxStringBytes: 2, MaxFields: 3, MaxCollectionLen: 4, MaxDepth: 5}
+	if opts.Limits != want || !opts.Limits.Enabled() {
+		t.Errorf("Limits = %+v, want %+v", opts.Limits, want)
+	}
+}
@@ -1,10 +1,29 @@
 package common
 
+import "time"
+
 const (
 	KeyTraceID  = "trace_id"
+	KeySpanID   = "span_id"
 	KeyUserID   = "user_id"
 	KeyPathname = "pathname"
 	KeyClientIP = "client_ip"
+	KeyTask     = "task"
+	KeyError    = "error"
+
+	KeyPanic      = "panic"
+	KeyStacktrace = "stacktrace"
+
+	KeyDeadlineRemainingMs = "deadline_remaining_ms"
+	KeyCtxErr              = "ctx_err"
+
+	KeyHTTPMethod    = "method"
+	KeyHTTPStatus    = "status"
+	KeyHTTPBytes     = "bytes"
+	KeyHTTPLatencyMs = "latency_ms"
+	KeyHTTPUserAgent = "user_agent"
+	// KeyAccessLog marks the access log entries of the HTTP middleware
+	KeyAccessLog = "access_log"
 
 	TimeFormat = "2006-01-02 15:04:05"
 )
@@ -14,6 +33,9 @@ const (
 	PathStderr    = "stderr"
 	EncodeConsole = "console"
 	EncodeJson    = "json"
+	EncodeGCP     = "gcp"
+	EncodeMsgpack = "msgpack"
+	EncodeCBOR    = "cbor"
 )
 
 const ()
@@ -24,6 +46,34 @@ type Options struct {
 	Encoding         string
 	Level            Level
 	CallerSkip       int
+	// Stacktrace captures a stack for entries at StacktraceLevel and above
+	Stacktrace      bool
+	StacktraceLevel Level
+	// TrimStacktrace drops runtime and glog frames from captured stacks
+	TrimStacktrace bool
+	// MultilineStacktrace renders console stacks as an indented block
+	// instead of an escaped field
+	MultilineStacktrace bool
+	// ConsoleLayout is the console line template, see WithConsoleLayout
+	ConsoleLayout string
+	// ConsoleTimeFormat is the time format of the console encoding,
+	// TimestampFormat when empty
+	ConsoleTimeFormat string
+	// TimestampFormat is the time format of all encodings, see
+	// WithTimestampFormat
+	TimestampFormat string
+	// Timezone converts entry times, see WithTimezone
+	Timezone *time.Location
+	// Pretty selects the developer-friendly console output
+	Pretty PrettyMode
+	// GCPProjectID builds the trace resource names of the gcp encoding
+	GCPProjectID string
+	// JSON shapes the entries of the JSON encoding
+	JSON   JSONOptions
+	Redact RedactOptions
+	Limits Limits
+	// Baggage is the baggage whitelist, see WithBaggageFields
+	Baggage BaggageOptions
 }
 
 type WithFunc func(o *Options)
@@ -73,6 +123,41 @@ func WithJsonEncoding() WithFunc {
 	}
 }
 
+// WithGCPEncoding uses the Google Cloud Logging structured JSON format.
+// projectID builds the logging.googleapis.com/trace resource names from
+// trace_id, which is kept as a plain field when projectID is empty.
+func WithGCPEncoding(projectID string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Encoding = EncodeGCP
+		o.GCPProjectID = projectID
+	}
+}
+
+// WithMsgpackEncoding writes entries as length-prefixed MessagePack maps,
+// see zap.DecodeBinaryLog to read them back as JSON
+func WithMsgpackEncoding() WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Encoding = EncodeMsgpack
+	}
+}
+
+// WithCBOREncoding writes entries as length-prefixed CBOR maps, see
+// zap.DecodeBinaryLog to read them back as JSON
+func WithCBOREncoding() WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Encoding = EncodeCBOR
+	}
+}
+
 func WithLevel(level Level) WithFunc {
 	return func(o *Options) {
 		if o == nil {
@@ -90,3 +175,61 @@ func WithCallerSkip(skip int) WithFunc {
 		o.CallerSkip = skip
 	}
 }
+
+// WithStacktraceLevel captures a stack trace for entries at level and above
+func WithStacktraceLevel(level Level) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Stacktrace = true
+		o.StacktraceLevel = level
+	}
+}
+
+// WithMultilineStacktrace renders stack traces in the console encoding as an
+// indented block below the entry instead of an escaped stacktrace field
+func WithMultilineStacktrace() WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.MultilineStacktrace = true
+	}
+}
+
+// WithStacktraceTrim drops runtime and glog frames from captured stack traces
+func WithStacktraceTrim() WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.TrimStacktrace = true
+	}
+}
+
+// WithConsoleLayout sets the template of console lines, for example
+// "[{time}] [{level:-5}] {caller} [{trace_id}] [{user_id}] {msg} {fields}".
+// The placeholders time, level, name, caller, msg and fields render the
+// entry, any other placeholder renders the field with that key, which is then
+// left out of the trailing fields. {key:10} pads a value to 10 characters
+// aligned right, {key:-10} aligned left, and {{ and }} are literal braces.
+func WithConsoleLayout(layout string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.ConsoleLayout = layout
+	}
+}
+
+// WithConsoleTimeFormat sets the time format of the console encoding, a named
+// format such as TimestampRFC3339 or a layout of time.Time.Format
+func WithConsoleTimeFormat(format string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.ConsoleTimeFormat = format
+	}
+}
@@ -0,0 +1,96 @@
+package common
+
+// OmitKey as a JSONKeys value leaves the field out of the entry
+const OmitKey = "-"
+
+// Default keys of the standard entry fields of the JSON encoding
+const (
+	DefaultJSONTimeKey       = "ts"
+	DefaultJSONLevelKey      = "level"
+	DefaultJSONNameKey       = "defaultLogger"
+	DefaultJSONCallerKey     = "caller"
+	DefaultJSONMessageKey    = "msg"
+	DefaultJSONStacktraceKey = KeyStacktrace
+)
+
+// DefaultJSONHoistKeys are the fields kept at the root when user fields are
+// nested, in addition to the registered top fields
+var DefaultJSONHoistKeys = []string{KeyTraceID, KeyUserID}
+
+// LevelCase selects how levels are written in the JSON encoding
+type LevelCase int
+
+const (
+	// LevelBracketed writes lowercase levels in brackets, "[info]"
+	LevelBracketed LevelCase = iota
+	// LevelLower writes lowercase levels, "info"
+	LevelLower
+	// LevelUpper writes uppercase levels, "INFO"
+	LevelUpper
+)
+
+// JSONKeys are the keys of the standard entry fields of the JSON encoding.
+// Empty keys keep the default, OmitKey drops the field.
+type JSONKeys struct {
+	Time       string
+	Level      string
+	Name       string
+	Caller     string
+	Message    string
+	Stacktrace string
+}
+
+// JSONOptions shape the entries of the JSON encoding
+type JSONOptions struct {
+	Keys JSONKeys
+	// FieldsKey nests the user fields in an object under this key, fields
+	// stay at the root when empty
+	FieldsKey string
+	// HoistKeys are the fields kept at the root when FieldsKey is set,
+	// DefaultJSONHoistKeys when nil. Registered top fields are always kept
+	// at the root.
+	HoistKeys []string
+	LevelCase LevelCase
+}
+
+// WithJSONKeys renames the standard entry fields of the JSON encoding
+func WithJSONKeys(keys JSONKeys) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.JSON.Keys = keys
+	}
+}
+
+// WithJSONFieldsKey nests the user fields of JSON, msgpack and cbor entries
+// under key, except trace_id, user_id and the registered top fields
+func WithJSONFieldsKey(key string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.JSON.FieldsKey = key
+	}
+}
+
+// WithJSONHoistKeys sets the fields kept at the root when user fields are
+// nested, replacing DefaultJSONHoistKeys
+func WithJSONHoistKeys(keys ...string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.JSON.HoistKeys = append([]string{}, keys...)
+	}
+}
+
+// WithJSONLevelCase sets how levels are written in the JSON encoding
+func WithJSONLevelCase(levelCase LevelCase) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.JSON.LevelCase = levelCase
+	}
+}
@@ -0,0 +1,94 @@
+package common
+
+// TruncatedBytesFormat marks a string or message cut to its size limit, with
+// the number of bytes removed
+const TruncatedBytesFormat = "…(truncated %d bytes)"
+
+// TruncatedElementsFormat marks a collection cut to its element limit, with
+// the number of elements removed
+const TruncatedElementsFormat = "…(truncated %d elements)"
+
+// TruncatedDepth replaces values nested deeper than the depth limit
+const TruncatedDepth = "…(truncated depth)"
+
+// KeyTruncatedFields holds the number of fields dropped by the field limit
+const KeyTruncatedFields = "truncated_fields"
+
+// Limits caps the size of entries before encoding. Zero values are
+// unlimited.
+type Limits struct {
+	// MaxMessageBytes caps the message length
+	MaxMessageBytes int
+	// MaxStringBytes caps each string value, including nested ones and
+	// error messages
+	MaxStringBytes int
+	// MaxFields caps the number of fields of an entry, context fields
+	// included, trace_id and the registered top fields are always kept
+	MaxFields int
+	// MaxCollectionLen caps the elements of slices, arrays, maps and
+	// marshaled objects
+	MaxCollectionLen int
+	// MaxDepth caps the nesting of collections and structs in a field value
+	MaxDepth int
+}
+
+// Enabled reports whether any limit is set
+func (l Limits) Enabled() bool {
+	return l.MaxMessageBytes > 0 || l.MaxStringBytes > 0 || l.MaxFields > 0 ||
+		l.MaxCollectionLen > 0 || l.MaxDepth > 0
+}
+
+// WithMaxMessageBytes truncates messages longer than n bytes
+func WithMaxMessageBytes(n int) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Limits.MaxMessageBytes = n
+	}
+}
+
+// WithMaxStringBytes truncates string values longer than n bytes, including
+// the strings nested in collections, marshaled objects and error messages
+func WithMaxStringBytes(n int) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Limits.MaxStringBytes = n
+	}
+}
+
+// WithMaxFields keeps the first n fields of an entry, context fields first,
+// and records how many were dropped under KeyTruncatedFields. trace_id and the
+// registered top fields are kept and not counted.
+func WithMaxFields(n int) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Limits.MaxFields = n
+	}
+}
+
+// WithMaxCollectionLen keeps the first n elements of slices, arrays, maps and
+// marshaled objects and arrays
+func WithMaxCollectionLen(n int) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Limits.MaxCollectionLen = n
+	}
+}
+
+// WithMaxDepth replaces collections and marshaled objects nested more than n
+// levels deep in a field value with TruncatedDepth
+func WithMaxDepth(n int) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Limits.MaxDepth = n
+	}
+}
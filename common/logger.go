@@ -22,3 +22,45 @@ type Logger interface {
 	Panic(args ...interface{})
 	Named(name string) Logger
 }
+
+// CallerSkipper is implemented by loggers that can adjust the caller they
+// report, for adapters that add their own frames to the call stack
+type CallerSkipper interface {
+	AddCallerSkip(skip int) Logger
+}
+
+// AddCallerSkip returns logger reporting the caller skip frames further up
+// the stack, or logger itself when it does not support caller skipping
+func AddCallerSkip(logger Logger, skip int) Logger {
+	if skipper, ok := logger.(CallerSkipper); ok && skip != 0 {
+		return skipper.AddCallerSkip(skip)
+	}
+	return logger
+}
+
+// StackLogger is implemented by loggers that can capture a stack trace for
+// the entries of a single call chain
+type StackLogger interface {
+	WithStack() Logger
+}
+
+// AddStack returns logger capturing a stack trace for every entry, or logger
+// itself when it does not support stack traces
+func AddStack(logger Logger) Logger {
+	if stacker, ok := logger.(StackLogger); ok {
+		return stacker.WithStack()
+	}
+	return logger
+}
+
+// SpanIDLogger is implemented by loggers that log the OpenTelemetry span ID
+// of the context, the gcp encoding does to link entries to their span
+type SpanIDLogger interface {
+	LogsSpanID() bool
+}
+
+// ErrorFielder is implemented by errors carrying fields that WithError adds
+// to the encoded error
+type ErrorFielder interface {
+	LogFields() map[string]interface{}
+}
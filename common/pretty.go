@@ -0,0 +1,30 @@
+package common
+
+// EnvNoColor disables colored console output when set to a non-empty value,
+// see https://no-color.org
+const EnvNoColor = "NO_COLOR"
+
+// PrettyMode selects the developer-friendly console output
+type PrettyMode int
+
+const (
+	// PrettyOff keeps the plain console layout
+	PrettyOff PrettyMode = iota
+	// PrettyAuto uses the pretty layout when every output is a terminal
+	// and NO_COLOR is not set
+	PrettyAuto
+	// PrettyOn always uses the pretty layout, colored unless NO_COLOR is set
+	PrettyOn
+)
+
+// WithPrettyConsole sets the pretty mode of the console encoding. The pretty
+// layout colors levels, dims times and callers, highlights trace_id, aligns
+// messages and prints multi-line field values and stacks as indented blocks.
+func WithPrettyConsole(mode PrettyMode) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Pretty = mode
+	}
+}
@@ -0,0 +1,103 @@
+package common
+
+import "regexp"
+
+// RedactMode is what happens to a redacted value
+type RedactMode int8
+
+const (
+	// RedactMask replaces the value with RedactedValue
+	RedactMask RedactMode = iota
+	// RedactDrop removes the field
+	RedactDrop
+	// RedactHash replaces the value with a salted SHA-256 prefix, so equal
+	// values can still be correlated
+	RedactHash
+)
+
+// RedactedValue replaces masked values
+const RedactedValue = "***"
+
+var (
+	RedactCreditCardPattern = regexp.MustCompile(`\b(?:4\d{3}|5[1-5]\d{2}|6011|3[47]\d{2})[ -]?\d{4}[ -]?\d{4}[ -]?\d{1,4}\b`)
+	RedactEmailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	RedactJWTPattern        = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)
+
+	// DefaultRedactKeys are the key rules enabled by WithRedaction
+	DefaultRedactKeys = []string{"password", "passwd", "secret", "*_secret", "token", "*_token",
+		"authorization", "cookie", "api_key", "apikey"}
+)
+
+// RedactOptions configures the redaction applied to fields before encoding,
+// including fields added to the context logger. Struct fields tagged
+// `glog:"redact"` are redacted whenever redaction is enabled.
+type RedactOptions struct {
+	Enabled bool
+	// Keys are case insensitive key names or path.Match glob patterns,
+	// matched against field keys and nested map and struct keys
+	Keys []string
+	// Patterns are masked, dropped or hashed where they match string values
+	Patterns []*regexp.Regexp
+	Mode     RedactMode
+	// Salt is prepended to values hashed with RedactHash. Without one a
+	// random salt is generated for the process, set it to correlate hashes
+	// across processes.
+	Salt string
+}
+
+// WithRedaction enables redaction with DefaultRedactKeys and the credit card
+// and JWT patterns
+func WithRedaction() WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Redact.Enabled = true
+		o.Redact.Keys = append(o.Redact.Keys, DefaultRedactKeys...)
+		o.Redact.Patterns = append(o.Redact.Patterns, RedactCreditCardPattern, RedactJWTPattern)
+	}
+}
+
+// WithRedactKeys redacts the values of fields whose keys match keys
+func WithRedactKeys(keys ...string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Redact.Enabled = true
+		o.Redact.Keys = append(o.Redact.Keys, keys...)
+	}
+}
+
+// WithRedactPatterns redacts the parts of string values matching patterns
+func WithRedactPatterns(patterns ...*regexp.Regexp) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Redact.Enabled = true
+		o.Redact.Patterns = append(o.Redact.Patterns, patterns...)
+	}
+}
+
+// WithRedactMode sets how redacted values are replaced
+func WithRedactMode(mode RedactMode) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Redact.Enabled = true
+		o.Redact.Mode = mode
+	}
+}
+
+// WithRedactSalt sets the salt of RedactHash, a random salt per process is
+// used when it is empty
+func WithRedactSalt(salt string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Redact.Salt = salt
+	}
+}
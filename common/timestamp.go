@@ -0,0 +1,39 @@
+package common
+
+import "time"
+
+// Named timestamp formats. Any other non-empty format is a layout of
+// time.Time.Format.
+const (
+	TimestampRFC3339      = "rfc3339"
+	TimestampRFC3339Milli = "rfc3339ms"
+	TimestampRFC3339Nano  = "rfc3339nano"
+	// Epoch formats are integers, numbers in the JSON encoding
+	TimestampEpochSeconds = "epoch_s"
+	TimestampEpochMillis  = "epoch_ms"
+	TimestampEpochNanos   = "epoch_ns"
+)
+
+// WithTimestampFormat sets the format of entry times in every encoding, a
+// named format such as TimestampRFC3339Nano or a custom layout. By default
+// the console uses 2006-01-02 15:04:05.000 and JSON TimestampRFC3339Milli.
+// WithConsoleTimeFormat overrides it for the console encoding.
+func WithTimestampFormat(format string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.TimestampFormat = format
+	}
+}
+
+// WithTimezone sets the timezone of entry times. By default JSON times are
+// in UTC and console times in local time.
+func WithTimezone(loc *time.Location) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Timezone = loc
+	}
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	topFieldKeys  atomic.Value // []string
+	topFieldMutex sync.Mutex
+)
+
+// RegisterTopField registers key as a top field. Encoders render registered
+// top fields in fixed positions next to trace_id, in registration order.
+// Registering the same key twice is a no-op.
+func RegisterTopField(key string) {
+	topFieldMutex.Lock()
+	defer topFieldMutex.Unlock()
+
+	keys := TopFields()
+	for _, k := range keys {
+		if k == key {
+			return
+		}
+	}
+	newKeys := make([]string, len(keys), len(keys)+1)
+	copy(newKeys, keys)
+	topFieldKeys.Store(append(newKeys, key))
+}
+
+// TopFields returns the registered top field keys. The returned slice must
+// not be modified.
+func TopFields() []string {
+	keys, _ := topFieldKeys.Load().([]string)
+	return keys
+}
+
+// IsTopField reports whether key has been registered as a top field
+func IsTopField(key string) bool {
+	for _, k := range TopFields() {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// UnregisterTopField removes key from the registered top fields
+func UnregisterTopField(key string) {
+	topFieldMutex.Lock()
+	defer topFieldMutex.Unlock()
+
+	keys := TopFields()
+	newKeys := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k != key {
+			newKeys = append(newKeys, k)
+		}
+	}
+	topFieldKeys.Store(newKeys)
+}
@@ -0,0 +1,231 @@
+// Package logrus helps migrating logrus code to glog. Entry mirrors the
+// logrus Entry API on top of a glog logger, and Hook forwards the entries of
+// an existing logrus logger into glog, so migrated and unmigrated modules
+// share the same output.
+package logrus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gw123/glog"
+	"github.com/gw123/glog/common"
+	sirupsen "github.com/sirupsen/logrus"
+)
+
+// entryFrames is the number of frames between a logging method and the glog
+// logger call: the exported method and Entry.log
+const entryFrames = 2
+
+type (
+	Fields = sirupsen.Fields
+	Level  = sirupsen.Level
+)
+
+// ErrorKey is the field WithError stores the error under, shared by logrus
+// and glog
+const ErrorKey = "error"
+
+// FieldLogger is the logrus FieldLogger method set returning glog backed
+// entries
+type FieldLogger interface {
+	WithField(key string, value interface{}) *Entry
+	WithFields(fields Fields) *Entry
+	WithError(err error) *Entry
+
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Printf(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+	Panicf(format string, args ...interface{})
+
+	Debug(args ...interface{})
+	Info(args ...interface{})
+	Print(args ...interface{})
+	Warn(args ...interface{})
+	Warning(args ...interface{})
+	Error(args ...interface{})
+	Fatal(args ...interface{})
+	Panic(args ...interface{})
+
+	Debugln(args ...interface{})
+	Infoln(args ...interface{})
+	Println(args ...interface{})
+	Warnln(args ...interface{})
+	Warningln(args ...interface{})
+	Errorln(args ...interface{})
+	Fatalln(args ...interface{})
+	Panicln(args ...interface{})
+}
+
+// Entry is a logrus style entry writing through a glog logger. Entries are
+// immutable, every With method returns a new one.
+type Entry struct {
+	logger common.Logger
+	ctx    context.Context
+	Data   Fields
+}
+
+var _ FieldLogger = (*Entry)(nil)
+
+// NewEntry returns an entry writing through logger. A nil logger uses the
+// default logger at the time of each call.
+func NewEntry(logger common.Logger) *Entry {
+	return &Entry{logger: logger, Data: Fields{}}
+}
+
+// WithContext returns an entry writing through the context logger of ctx,
+// with its trace_id and fields, instead of the entry logger
+func (e *Entry) WithContext(ctx context.Context) *Entry {
+	return &Entry{logger: e.logger, ctx: ctx, Data: e.Data}
+}
+
+// Context returns the context set by WithContext
+func (e *Entry) Context() context.Context {
+	return e.ctx
+}
+
+func (e *Entry) WithField(key string, value interface{}) *Entry {
+	return e.WithFields(Fields{key: value})
+}
+
+func (e *Entry) WithFields(fields Fields) *Entry {
+	data := make(Fields, len(e.Data)+len(fields))
+	for k, v := range e.Data {
+		data[k] = v
+	}
+	for k, v := range fields {
+		data[k] = v
+	}
+	return &Entry{logger: e.logger, ctx: e.ctx, Data: data}
+}
+
+func (e *Entry) WithError(err error) *Entry {
+	return e.WithField(ErrorKey, err)
+}
+
+// Log logs args at a logrus level, trace level is logged as debug
+func (e *Entry) Log(level Level, args ...interface{}) {
+	e.log(level, fmt.Sprint(args...))
+}
+
+// Logf logs a formatted message at a logrus level
+func (e *Entry) Logf(level Level, format string, args ...interface{}) {
+	e.log(level, fmt.Sprintf(format, args...))
+}
+
+func (e *Entry) Trace(args ...interface{})   { e.log(sirupsen.TraceLevel, fmt.Sprint(args...)) }
+func (e *Entry) Debug(args ...interface{})   { e.log(sirupsen.DebugLevel, fmt.Sprint(args...)) }
+func (e *Entry) Info(args ...interface{})    { e.log(sirupsen.InfoLevel, fmt.Sprint(args...)) }
+func (e *Entry) Print(args ...interface{})   { e.log(sirupsen.InfoLevel, fmt.Sprint(args...)) }
+func (e *Entry) Warn(args ...interface{})    { e.log(sirupsen.WarnLevel, fmt.Sprint(args...)) }
+func (e *Entry) Warning(args ...interface{}) { e.log(sirupsen.WarnLevel, fmt.Sprint(args...)) }
+func (e *Entry) Error(args ...interface{})   { e.log(sirupsen.ErrorLevel, fmt.Sprint(args...)) }
+func (e *Entry) Fatal(args ...interface{})   { e.log(sirupsen.FatalLevel, fmt.Sprint(args...)) }
+func (e *Entry) Panic(args ...interface{})   { e.log(sirupsen.PanicLevel, fmt.Sprint(args...)) }
+
+func (e *Entry) Tracef(format string, args ...interface{}) {
+	e.log(sirupsen.TraceLevel, fmt.Sprintf(format, args...))
+}
+
+func (e *Entry) Debugf(format string, args ...interface{}) {
+	e.log(sirupsen.DebugLevel, fmt.Sprintf(format, args...))
+}
+
+func (e *Entry) Infof(format string, args ...interface{}) {
+	e.log(sirupsen.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+func (e *Entry) Printf(format string, args ...interface{}) {
+	e.log(sirupsen.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+func (e *Entry) Warnf(format string, args ...interface{}) {
+	e.log(sirupsen.WarnLevel, fmt.Sprintf(format, args...))
+}
+
+func (e *Entry) Warningf(format string, args ...interface{}) {
+	e.log(sirupsen.WarnLevel, fmt.Sprintf(format, args...))
+}
+
+func (e *Entry) Errorf(format string, args ...interface{}) {
+	e.log(sirupsen.ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+func (e *Entry) Fatalf(format string, args ...interface{}) {
+	e.log(sirupsen.FatalLevel, fmt.Sprintf(format, args...))
+}
+
+func (e *Entry) Panicf(format string, args ...interface{}) {
+	e.log(sirupsen.PanicLevel, fmt.Sprintf(format, args...))
+}
+
+func (e *Entry) Traceln(args ...interface{})   { e.log(sirupsen.TraceLevel, sprintln(args...)) }
+func (e *Entry) Debugln(args ...interface{})   { e.log(sirupsen.DebugLevel, sprintln(args...)) }
+func (e *Entry) Infoln(args ...interface{})    { e.log(sirupsen.InfoLevel, sprintln(args...)) }
+func (e *Entry) Println(args ...interface{})   { e.log(sirupsen.InfoLevel, sprintln(args...)) }
+func (e *Entry) Warnln(args ...interface{})    { e.log(sirupsen.WarnLevel, sprintln(args...)) }
+func (e *Entry) Warningln(args ...interface{}) { e.log(sirupsen.WarnLevel, sprintln(args...)) }
+func (e *Entry) Errorln(args ...interface{})   { e.log(sirupsen.ErrorLevel, sprintln(args...)) }
+func (e *Entry) Fatalln(args ...interface{})   { e.log(sirupsen.FatalLevel, sprintln(args...)) }
+func (e *Entry) Panicln(args ...interface{})   { e.log(sirupsen.PanicLevel, sprintln(args...)) }
+
+// log must be called directly by the exported logging methods, see
+// entryFrames
+func (e *Entry) log(level Level, msg string) {
+	var logger common.Logger
+	switch {
+	case e.ctx != nil:
+		logger = glog.ExtractEntry(e.ctx)
+	case e.logger != nil:
+		logger = e.logger
+	default:
+		logger = glog.DefaultLogger()
+	}
+	logger = withData(common.AddCallerSkip(logger, entryFrames), e.Data)
+
+	switch level {
+	case sirupsen.PanicLevel:
+		logger.Panic(msg)
+	case sirupsen.FatalLevel:
+		logger.Fatal(msg)
+	case sirupsen.ErrorLevel:
+		logger.Error(msg)
+	case sirupsen.WarnLevel:
+		logger.Warn(msg)
+	case sirupsen.InfoLevel:
+		logger.Info(msg)
+	default:
+		logger.Debug(msg)
+	}
+}
+
+// withData adds logrus fields to logger, logging an error under ErrorKey the
+// way glog WithError does
+func withData(logger common.Logger, data Fields) common.Logger {
+	if len(data) == 0 {
+		return logger
+	}
+	fields := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if err, ok := v.(error); ok && k == ErrorKey {
+			logger = logger.WithError(err)
+			continue
+		}
+		fields[k] = v
+	}
+	if len(fields) == 0 {
+		return logger
+	}
+	return logger.WithFields(fields)
+}
+
+// sprintln formats like fmt.Sprintln without the trailing newline, as logrus
+// does
+func sprintln(args ...interface{}) string {
+	msg := fmt.Sprintln(args...)
+	return msg[:len(msg)-1]
+}
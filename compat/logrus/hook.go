@@ -0,0 +1,90 @@
+package logrus
+
+import (
+	"runtime"
+	"strings"
+
+	"github.com/gw123/glog"
+	"github.com/gw123/glog/common"
+	sirupsen "github.com/sirupsen/logrus"
+)
+
+// hookFrames is the number of glog frames between Hook.Fire and the logger
+// call: Fire and fire
+const hookFrames = 2
+
+const logrusPackage = "github.com/sirupsen/logrus."
+
+// Hook is a logrus hook forwarding entries into glog. Entries carrying a
+// context use its context logger and trace_id. Set the output of the logrus
+// logger to io.Discard to avoid writing every entry twice.
+type Hook struct {
+	logger common.Logger
+	levels []sirupsen.Level
+}
+
+var _ sirupsen.Hook = (*Hook)(nil)
+
+// NewHook returns a hook forwarding entries at levels, or at all levels when
+// none are given, to logger. A nil logger uses the default logger at the
+// time of each entry.
+func NewHook(logger common.Logger, levels ...sirupsen.Level) *Hook {
+	if len(levels) == 0 {
+		levels = sirupsen.AllLevels
+	}
+	return &Hook{logger: logger, levels: levels}
+}
+
+func (h *Hook) Levels() []sirupsen.Level {
+	return h.levels
+}
+
+// Fire logs entry through glog. Panic and fatal entries are logged at error
+// level since logrus panics or exits itself after running the hooks.
+func (h *Hook) Fire(entry *sirupsen.Entry) error {
+	h.fire(entry, logrusDepth())
+	return nil
+}
+
+func (h *Hook) fire(entry *sirupsen.Entry, skip int) {
+	var logger common.Logger
+	switch {
+	case entry.Context != nil:
+		logger = glog.ExtractEntry(entry.Context)
+	case h.logger != nil:
+		logger = h.logger
+	default:
+		logger = glog.DefaultLogger()
+	}
+	logger = withData(common.AddCallerSkip(logger, hookFrames+skip), entry.Data)
+
+	switch entry.Level {
+	case sirupsen.PanicLevel, sirupsen.FatalLevel, sirupsen.ErrorLevel:
+		logger.Error(entry.Message)
+	case sirupsen.WarnLevel:
+		logger.Warn(entry.Message)
+	case sirupsen.InfoLevel:
+		logger.Info(entry.Message)
+	default:
+		logger.Debug(entry.Message)
+	}
+}
+
+// logrusDepth counts the logrus frames above Hook.Fire so the logrus call
+// site is reported as the caller
+func logrusDepth() int {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	depth := 0
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, logrusPackage) {
+			return depth
+		}
+		depth++
+		if !more {
+			return depth
+		}
+	}
+}
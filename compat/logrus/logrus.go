@@ -0,0 +1,68 @@
+package logrus
+
+import (
+	"context"
+	"fmt"
+
+	sirupsen "github.com/sirupsen/logrus"
+)
+
+// The package level functions write through the glog default logger, so
+// `logrus.WithField(...)` call sites only need their import path changed.
+
+func WithField(key string, value interface{}) *Entry {
+	return NewEntry(nil).WithField(key, value)
+}
+
+func WithFields(fields Fields) *Entry {
+	return NewEntry(nil).WithFields(fields)
+}
+
+func WithError(err error) *Entry {
+	return NewEntry(nil).WithError(err)
+}
+
+func WithContext(ctx context.Context) *Entry {
+	return NewEntry(nil).WithContext(ctx)
+}
+
+func Debug(args ...interface{})   { NewEntry(nil).log(sirupsen.DebugLevel, fmt.Sprint(args...)) }
+func Info(args ...interface{})    { NewEntry(nil).log(sirupsen.InfoLevel, fmt.Sprint(args...)) }
+func Print(args ...interface{})   { NewEntry(nil).log(sirupsen.InfoLevel, fmt.Sprint(args...)) }
+func Warn(args ...interface{})    { NewEntry(nil).log(sirupsen.WarnLevel, fmt.Sprint(args...)) }
+func Warning(args ...interface{}) { NewEntry(nil).log(sirupsen.WarnLevel, fmt.Sprint(args...)) }
+func Error(args ...interface{})   { NewEntry(nil).log(sirupsen.ErrorLevel, fmt.Sprint(args...)) }
+func Fatal(args ...interface{})   { NewEntry(nil).log(sirupsen.FatalLevel, fmt.Sprint(args...)) }
+func Panic(args ...interface{})   { NewEntry(nil).log(sirupsen.PanicLevel, fmt.Sprint(args...)) }
+
+func Debugf(format string, args ...interface{}) {
+	NewEntry(nil).log(sirupsen.DebugLevel, fmt.Sprintf(format, args...))
+}
+
+func Infof(format string, args ...interface{}) {
+	NewEntry(nil).log(sirupsen.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+func Printf(format string, args ...interface{}) {
+	NewEntry(nil).log(sirupsen.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+func Warnf(format string, args ...interface{}) {
+	NewEntry(nil).log(sirupsen.WarnLevel, fmt.Sprintf(format, args...))
+}
+
+func Warningf(format string, args ...interface{}) {
+	NewEntry(nil).log(sirupsen.WarnLevel, fmt.Sprintf(format, args...))
+}
+
+func Errorf(format string, args ...interface{}) {
+	NewEntry(nil).log(sirupsen.ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+func Fatalf(format string, args ...interface{}) {
+	NewEntry(nil).log(sirupsen.FatalLevel, fmt.Sprintf(format, args...))
+}
+
+func Panicf(format string, args ...interface{}) {
+	NewEntry(nil).log(sirupsen.PanicLevel, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,126 @@
+package logrus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog"
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+	sirupsen "github.com/sirupsen/logrus"
+)
+
+func TestEntry_Fields(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.DebugLevel))
+	var log FieldLogger = NewEntry(logger)
+
+	log.WithFields(Fields{"user": "bob", "attempt": 2}).WithError(errors.New("denied")).Warnf("login %s", "failed")
+	log.Debugln("a", "b")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %v", len(lines), lines)
+	}
+	for _, want := range []string{`"level":"[warn]"`, `"msg":"login failed"`, `"user":"bob"`, `"attempt":2`, `"error":{"msg":"denied"`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("log should contain %s, got %q", want, lines[0])
+		}
+	}
+	if !strings.Contains(lines[1], `"msg":"a b"`) || !strings.Contains(lines[1], `"level":"[debug]"`) {
+		t.Errorf("Debugln() should log without the trailing newline, got %q", lines[1])
+	}
+}
+
+func TestEntry_Immutable(t *testing.T) {
+	base := NewEntry(nil).WithField("a", 1)
+	child := base.WithField("b", 2)
+
+	if len(base.Data) != 1 || len(child.Data) != 2 {
+		t.Errorf("WithField() should not modify the parent entry, got %v and %v", base.Data, child.Data)
+	}
+}
+
+func TestEntry_Context(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := glog.ToContext(context.Background(), logger)
+	glog.AddTraceID(ctx, "logrus-trace")
+
+	NewEntry(nil).WithContext(ctx).WithField("k", "v").Info("with ctx")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"trace_id":"logrus-trace"`) || !strings.Contains(lines[0], `"k":"v"`) {
+		t.Errorf("the context logger should be used, got %v", lines)
+	}
+}
+
+func TestEntry_Caller(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	entry := NewEntry(logger)
+
+	_, _, line, _ := runtime.Caller(0)
+	entry.Infof("caller %d", 1)
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], "logrus_test.go:"+strconv.Itoa(line+1)) {
+		t.Errorf("caller should be the Infof call site, got %v", lines)
+	}
+}
+
+func TestEntry_Panic(t *testing.T) {
+	logger, _ := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	defer func() {
+		if recover() == nil {
+			t.Error("Panic() should panic")
+		}
+	}()
+	NewEntry(logger).Panic("boom")
+}
+
+func TestHook(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.DebugLevel))
+	lr := sirupsen.New()
+	lr.SetOutput(io.Discard)
+	lr.SetLevel(sirupsen.DebugLevel)
+	lr.AddHook(NewHook(logger))
+
+	_, _, line, _ := runtime.Caller(0)
+	lr.WithField("module", "billing").WithError(errors.New("timeout")).Errorf("charge %d failed", 42)
+	lr.Debug("debug entry")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %v", len(lines), lines)
+	}
+	for _, want := range []string{`"level":"[error]"`, `"msg":"charge 42 failed"`, `"module":"billing"`,
+		`"error":{"msg":"timeout"`, "logrus_test.go:" + strconv.Itoa(line+1)} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("forwarded entry should contain %s, got %q", want, lines[0])
+		}
+	}
+	if !strings.Contains(lines[1], `"level":"[debug]"`) {
+		t.Errorf("debug entry should be forwarded at debug level, got %q", lines[1])
+	}
+}
+
+func TestHook_ContextAndLevels(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.DebugLevel))
+	ctx := glog.ToContext(context.Background(), logger)
+	glog.AddTraceID(ctx, "hook-trace")
+
+	lr := sirupsen.New()
+	lr.SetOutput(io.Discard)
+	lr.AddHook(NewHook(nil, sirupsen.WarnLevel, sirupsen.ErrorLevel))
+
+	lr.WithContext(ctx).Info("not forwarded")
+	lr.WithContext(ctx).Warn("forwarded")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], "forwarded") || !strings.Contains(lines[0], `"trace_id":"hook-trace"`) {
+		t.Errorf("only warn entries should be forwarded through the ctx logger, got %v", lines)
+	}
+}
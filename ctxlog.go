@@ -2,8 +2,11 @@ package glog
 
 import (
 	"context"
-	"errors"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/gw123/glog/common"
 	"go.opentelemetry.io/otel/trace"
@@ -15,6 +18,7 @@ type ctxLogger struct {
 	logger    common.Logger
 	fields    map[string]interface{}
 	topFields map[string]interface{}
+	buffer    *logBuffer
 	mutex     sync.RWMutex
 }
 
@@ -24,6 +28,13 @@ var (
 	ctxLoggerKey = &ctxLoggerMarker{}
 )
 
+// HasContextLogger reports whether ctx carries a context logger, the TopKey
+// getters panic without one when IsDebug is set
+func HasContextLogger(ctx context.Context) bool {
+	l, ok := ctx.Value(ctxLoggerKey).(*ctxLogger)
+	return ok && l != nil
+}
+
 // AddFields adds multiple log fields to the context logger
 func AddFields(ctx context.Context, fields map[string]interface{}) {
 	l, ok := ctx.Value(ctxLoggerKey).(*ctxLogger)
@@ -77,23 +88,21 @@ func AddField(ctx context.Context, key string, val interface{}) {
 
 // AddTraceID adds a trace ID for aggregating logs from the same request
 func AddTraceID(ctx context.Context, traceID string) {
-	AddTopField(ctx, common.KeyTraceID, traceID)
+	TraceIDKey.Set(ctx, traceID)
 }
 
-// ExtractTraceID extracts the trace ID from the context
-func ExtractTraceID(ctx context.Context) string {
-	l, ok := ctx.Value(ctxLoggerKey).(*ctxLogger)
-	if !ok || l == nil {
-		if IsDebug {
-			panic(errors.New("not set ctxLogger"))
-		}
-		return ""
+// NewTraceID generates a random trace ID in the OpenTelemetry hex format
+func NewTraceID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
 	}
+	return hex.EncodeToString(id[:])
+}
 
-	l.mutex.RLock()
-	val, ok := l.topFields[common.KeyTraceID].(string)
-	l.mutex.RUnlock()
-	if ok {
+// ExtractTraceID extracts the trace ID from the context
+func ExtractTraceID(ctx context.Context) string {
+	if val, ok := TraceIDKey.Get(ctx); ok {
 		return val
 	}
 
@@ -103,7 +112,23 @@ func ExtractTraceID(ctx context.Context) string {
 	return ""
 }
 
-// WithOTEL extracts OpenTelemetry trace ID from context and adds it to the logger
+// ExtractSpanID extracts the OpenTelemetry span ID from the context
+func ExtractSpanID(ctx context.Context) string {
+	if span := trace.SpanContextFromContext(ctx); span.SpanID().IsValid() {
+		return span.SpanID().String()
+	}
+	return ""
+}
+
+// logsSpanID reports whether logger logs the span ID of the context, see
+// common.SpanIDLogger
+func logsSpanID(logger common.Logger) bool {
+	spanLogger, ok := logger.(common.SpanIDLogger)
+	return ok && spanLogger.LogsSpanID()
+}
+
+// WithOTEL extracts OpenTelemetry trace IDs, and span IDs for loggers that
+// log them, from context and adds them to the logger
 func WithOTEL(ctx context.Context) common.Logger {
 	l, ok := ctx.Value(ctxLoggerKey).(*ctxLogger)
 	var logger common.Logger
@@ -114,57 +139,45 @@ func WithOTEL(ctx context.Context) common.Logger {
 	}
 
 	if span := trace.SpanContextFromContext(ctx); span.TraceID().IsValid() {
-		return logger.WithField("trace_id", span.TraceID().String())
+		logger = logger.WithField("trace_id", span.TraceID().String())
+	}
+	if sID := ExtractSpanID(ctx); sID != "" && logsSpanID(logger) {
+		logger = logger.WithField(common.KeySpanID, sID)
 	}
 	return logger
 }
 
 // AddUserID add userID to ctx
 func AddUserID(ctx context.Context, userID int64) {
-	AddTopField(ctx, common.KeyUserID, userID)
+	UserIDKey.Set(ctx, userID)
 }
 
 // ExtractUserID userID
 func ExtractUserID(ctx context.Context) int64 {
-	l, ok := ctx.Value(ctxLoggerKey).(*ctxLogger)
-	if !ok || l == nil {
-		if IsDebug {
-			panic(errors.New("not set ctxLogger"))
-		}
-		return 0
-	}
-
-	l.mutex.RLock()
-	val, ok := l.topFields[common.KeyUserID].(int64)
-	l.mutex.RUnlock()
-	if ok {
-		return val
-	}
-	return 0
+	val, _ := UserIDKey.Get(ctx)
+	return val
 }
 
-// AddPathname add userID to ctx
+// AddPathname add pathname to ctx
 func AddPathname(ctx context.Context, pathname string) {
-	AddTopField(ctx, common.KeyPathname, pathname)
+	PathnameKey.Set(ctx, pathname)
 }
 
 // ExtractPathname pathname
 func ExtractPathname(ctx context.Context) string {
-	l, ok := ctx.Value(ctxLoggerKey).(*ctxLogger)
-	if !ok || l == nil {
-		if IsDebug {
-			panic(errors.New("not set ctxLogger"))
-		}
-		return ""
-	}
+	val, _ := PathnameKey.Get(ctx)
+	return val
+}
 
-	l.mutex.RLock()
-	val, ok := l.topFields[common.KeyPathname].(string)
-	l.mutex.RUnlock()
-	if ok {
-		return val
-	}
-	return ""
+// AddClientIP add client ip to ctx
+func AddClientIP(ctx context.Context, clientIP string) {
+	ClientIPKey.Set(ctx, clientIP)
+}
+
+// ExtractClientIP client ip
+func ExtractClientIP(ctx context.Context) string {
+	val, _ := ClientIPKey.Get(ctx)
+	return val
 }
 
 // ToContext adds a logger to the context for use in subsequent log operations
@@ -180,19 +193,59 @@ func ToContext(ctx context.Context, entry common.Logger) context.Context {
 
 // ExtractEntry extracts the logger from context with all accumulated fields
 func ExtractEntry(ctx context.Context) common.Logger {
-	var logger common.Logger
+	return extractEntry(ctx, nil, false)
+}
+
+// ExtractEntryOr extracts the logger from context like ExtractEntry, using
+// fallback instead of the default logger when ctx carries no context logger
+func ExtractEntryOr(ctx context.Context, fallback common.Logger) common.Logger {
+	return extractEntry(ctx, fallback, false)
+}
+
+// extractEntry returns the context logger of ctx, or base when ctx carries
+// none or sink is set, with the fields accumulated in ctx. Without base the
+// default logger is used.
+func extractEntry(ctx context.Context, base common.Logger, sink bool) common.Logger {
+	if base == nil {
+		base = DefaultLogger()
+	}
+	logger := base
+	var buffer *logBuffer
 	l, ok := ctx.Value(ctxLoggerKey).(*ctxLogger)
-	if ok && l != nil {
+	hasLogger := ok && l != nil
+	if hasLogger {
 		l.mutex.RLock()
 		topFields := l.topFields
 		fields := l.fields
+		buffer = l.buffer
 		l.mutex.RUnlock()
-		logger = l.logger.WithFields(topFields).WithFields(fields)
-	} else {
-		logger = DefaultLogger()
+		if !sink {
+			logger = l.logger
+		}
+		logger = logger.WithFields(topFields).WithFields(fields)
+	}
+	if bagFields := extractBaggageFields(ctx, logger); len(bagFields) > 0 {
+		logger = logger.WithFields(bagFields)
+	}
+	if deadlineFields := extractDeadlineFields(ctx); len(deadlineFields) > 0 {
+		logger = logger.WithFields(deadlineFields)
+	}
+	// A sink logs records of contexts without a context logger too, where
+	// the trace ID getter panics when IsDebug is set
+	tID := ""
+	if hasLogger || !sink {
+		tID = ExtractTraceID(ctx)
+	} else if span := trace.SpanContextFromContext(ctx); span.TraceID().IsValid() {
+		tID = span.TraceID().String()
+	}
+	if tID != "" {
+		logger = logger.WithField("trace_id", tID)
+	}
+	if sID := ExtractSpanID(ctx); sID != "" && logsSpanID(logger) {
+		logger = logger.WithField(common.KeySpanID, sID)
 	}
-	if tID := ExtractTraceID(ctx); tID != "" {
-		return logger.WithField("trace_id", tID)
+	if buffer != nil {
+		logger = withBuffer(logger, buffer)
 	}
 	return logger
 }
@@ -0,0 +1,52 @@
+package glog
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/gw123/glog/common"
+)
+
+var deadlineFieldsEnabled int32
+
+// SetDeadlineFields enables or disables the context state fields added by
+// ExtractEntry: deadline_remaining_ms when the context has a deadline and
+// ctx_err (canceled or deadline_exceeded) once the context is done
+func SetDeadlineFields(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&deadlineFieldsEnabled, v)
+}
+
+// extractDeadlineFields returns the deadline and cancellation state of ctx
+func extractDeadlineFields(ctx context.Context) map[string]interface{} {
+	if atomic.LoadInt32(&deadlineFieldsEnabled) == 0 {
+		return nil
+	}
+
+	var fields map[string]interface{}
+	if deadline, ok := ctx.Deadline(); ok {
+		fields = map[string]interface{}{
+			common.KeyDeadlineRemainingMs: time.Until(deadline).Milliseconds(),
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		if fields == nil {
+			fields = map[string]interface{}{}
+		}
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			fields[common.KeyCtxErr] = "deadline_exceeded"
+		case errors.Is(err, context.Canceled):
+			fields[common.KeyCtxErr] = "canceled"
+		default:
+			fields[common.KeyCtxErr] = err.Error()
+		}
+	}
+	return fields
+}
@@ -0,0 +1,80 @@
+package glog
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+)
+
+func TestExtractDeadlineFields_Disabled(t *testing.T) {
+	SetDeadlineFields(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if fields := extractDeadlineFields(ctx); fields != nil {
+		t.Errorf("extractDeadlineFields() = %v, want nil when disabled", fields)
+	}
+}
+
+func TestExtractDeadlineFields_Remaining(t *testing.T) {
+	SetDeadlineFields(true)
+	defer SetDeadlineFields(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	fields := extractDeadlineFields(ctx)
+	remaining, ok := fields[common.KeyDeadlineRemainingMs].(int64)
+	if !ok || remaining <= 0 || remaining > time.Minute.Milliseconds() {
+		t.Errorf("deadline_remaining_ms = %v, want a value within one minute", fields[common.KeyDeadlineRemainingMs])
+	}
+	if _, ok := fields[common.KeyCtxErr]; ok {
+		t.Error("ctx_err should not be set for a live context")
+	}
+}
+
+func TestExtractDeadlineFields_Errors(t *testing.T) {
+	SetDeadlineFields(true)
+	defer SetDeadlineFields(false)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if fields := extractDeadlineFields(canceled); fields[common.KeyCtxErr] != "canceled" {
+		t.Errorf("ctx_err = %v, want canceled", fields[common.KeyCtxErr])
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	fields := extractDeadlineFields(expired)
+	if fields[common.KeyCtxErr] != "deadline_exceeded" {
+		t.Errorf("ctx_err = %v, want deadline_exceeded", fields[common.KeyCtxErr])
+	}
+	if remaining, _ := fields[common.KeyDeadlineRemainingMs].(int64); remaining >= 0 {
+		t.Errorf("deadline_remaining_ms = %v, want a negative value after the deadline", remaining)
+	}
+
+	if fields := extractDeadlineFields(context.Background()); fields != nil {
+		t.Errorf("extractDeadlineFields() = %v, want nil for a background context", fields)
+	}
+}
+
+func TestExtractEntry_WithDeadlineFields(t *testing.T) {
+	SetDeadlineFields(true)
+	defer SetDeadlineFields(false)
+
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = ToContext(ctx, logger)
+	cancel()
+
+	ExtractEntry(ctx).Info("after cancel")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"ctx_err":"canceled"`) {
+		t.Errorf("log should contain ctx_err, got %v", lines)
+	}
+}
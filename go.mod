@@ -1,14 +1,24 @@
 module github.com/gw123/glog
 
-go 1.17
+go 1.21
 
 require (
+	github.com/go-logr/logr v1.4.2
+	github.com/sirupsen/logrus v1.9.3
+	go.opentelemetry.io/otel v1.1.0
 	go.opentelemetry.io/otel/trace v1.1.0
 	go.uber.org/zap v1.24.0
+	google.golang.org/grpc v1.60.1
+	gorm.io/gorm v1.25.12
 )
 
 require (
-	go.opentelemetry.io/otel v1.1.0 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
+	golang.org/x/net v0.16.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20231002182017-d307bd883b97 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
 )
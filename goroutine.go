@@ -0,0 +1,89 @@
+package glog
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/gw123/glog/common"
+)
+
+// detachedContext keeps the values of its parent but is never cancelled
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// forkContext returns a context detached from the cancellation of ctx with a
+// copy of its context logger, so fields added later on either side are not
+// shared
+func forkContext(ctx context.Context) context.Context {
+	detached := context.Context(detachedContext{parent: ctx})
+	l, ok := ctx.Value(ctxLoggerKey).(*ctxLogger)
+	if !ok || l == nil {
+		return ToContext(detached, DefaultLogger())
+	}
+
+	l.mutex.RLock()
+	forked := &ctxLogger{
+		logger:    l.logger,
+		fields:    l.fields,
+		topFields: l.topFields,
+	}
+	l.mutex.RUnlock()
+	return context.WithValue(detached, ctxLoggerKey, forked)
+}
+
+// Go runs fn in a new goroutine as a named background task. The task gets a
+// context that is not cancelled with ctx and a forked copy of its log fields
+// plus a task field. Start, finish and duration are logged, and a panic in
+// fn is recovered and logged with its stack. The returned channel is closed
+// once the task has finished.
+func Go(ctx context.Context, name string, fn func(ctx context.Context)) <-chan struct{} {
+	taskCtx := forkContext(ctx)
+	AddField(taskCtx, common.KeyTask, name)
+	done := make(chan struct{})
+	start := time.Now()
+	logger := func() common.Logger {
+		return ExtractEntry(taskCtx).WithField("duration_ms", time.Since(start).Milliseconds())
+	}
+
+	panicked := false
+	goRecovered(func() {
+		ExtractEntry(taskCtx).Info("task started")
+		fn(taskCtx)
+	}, func(r interface{}, depth int) {
+		panicked = true
+		// logPanic and this function
+		logPanic(common.AddCallerSkip(logger(), 2+depth), r, debug.Stack(), "task panicked")
+	}, func() {
+		if !panicked {
+			logger().Info("task finished")
+		}
+		close(done)
+	})
+	return done
+}
+
+// goRecovered runs fn in a new goroutine. A panic in fn is recovered and
+// passed to recovered with the number of frames between recovered and the
+// panic site, then done is called once fn has returned or panicked.
+func goRecovered(fn func(), recovered func(r interface{}, depth int), done func()) {
+	go func() {
+		defer done()
+		defer func() {
+			if r := recover(); r != nil {
+				// The deferred function and the runtime frames below it
+				recovered(r, 1+panicDepth())
+			}
+		}()
+		fn()
+	}()
+}
@@ -0,0 +1,121 @@
+package glog
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+)
+
+func TestGo_DetachesCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = ToContext(ctx, DefaultLogger())
+	cancel()
+
+	var taskErr error
+	<-Go(ctx, "detached", func(ctx context.Context) {
+		taskErr = ctx.Err()
+	})
+	if taskErr != nil {
+		t.Errorf("task context should not be cancelled, got %v", taskErr)
+	}
+}
+
+func TestGo_ForksFields(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+	AddTraceID(ctx, "go-trace")
+	AddField(ctx, "shared", "before")
+
+	started := make(chan struct{})
+	proceed := make(chan struct{})
+	done := Go(ctx, "worker", func(taskCtx context.Context) {
+		AddField(taskCtx, "task_only", "yes")
+		close(started)
+		<-proceed
+		ExtractEntry(taskCtx).Info("worker log")
+	})
+
+	<-started
+	AddField(ctx, "shared", "after")
+	close(proceed)
+	<-done
+
+	ExtractEntry(ctx).Info("request log")
+
+	var workerLine, requestLine string
+	for _, line := range logtest.ReadLines(t, logFile) {
+		switch {
+		case strings.Contains(line, "worker log"):
+			workerLine = line
+		case strings.Contains(line, "request log"):
+			requestLine = line
+		}
+	}
+	if !strings.Contains(workerLine, `"task":"worker"`) || !strings.Contains(workerLine, `"shared":"before"`) ||
+		!strings.Contains(workerLine, "go-trace") {
+		t.Errorf("worker log should carry the forked fields, got %q", workerLine)
+	}
+	if strings.Contains(requestLine, "task_only") || strings.Contains(requestLine, `"task"`) {
+		t.Errorf("task fields should not leak into the request, got %q", requestLine)
+	}
+}
+
+func TestGo_RecoversPanic(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+
+	var line int
+	<-Go(ctx, "panicker", func(ctx context.Context) {
+		_, _, line, _ = runtime.Caller(0)
+		panic("worker exploded")
+	})
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %v", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], "task started") {
+		t.Errorf("first line should log the start, got %q", lines[0])
+	}
+	last := lines[1]
+	for _, want := range []string{"task panicked", "worker exploded", "stacktrace", "duration_ms", "[error]",
+		"goroutine_test.go:" + strconv.Itoa(line+1)} {
+		if !strings.Contains(last, want) {
+			t.Errorf("panic log should contain %q, got %q", want, last)
+		}
+	}
+}
+
+func TestGo_PanicStackKey(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithStacktraceLevel(common.ErrorLevel))
+	ctx := ToContext(context.Background(), logger)
+
+	<-Go(ctx, "panicker", func(ctx context.Context) {
+		panic("worker exploded")
+	})
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %v", len(lines), lines)
+	}
+	if n := strings.Count(lines[1], `"stacktrace"`); n != 1 ||
+		!strings.Contains(lines[1], `"stacktrace":"github.com/gw123/glog.TestGo_PanicStackKey.func1\n`) {
+		t.Errorf("the panic stack should be the only stacktrace key, got %q", lines[1])
+	}
+}
+
+func TestGo_NoContextLogger(t *testing.T) {
+	ran := false
+	<-Go(context.Background(), "plain", func(ctx context.Context) {
+		ran = true
+		ExtractEntry(ctx).Info("task without request logger")
+	})
+	if !ran {
+		t.Error("Go() should run the task")
+	}
+}
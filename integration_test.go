@@ -2,10 +2,15 @@ package glog
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestIntegration_FullWorkflow(t *testing.T) {
@@ -278,3 +283,106 @@ func BenchmarkIntegration_ConcurrentWorkflow(b *testing.B) {
 		}
 	})
 }
+
+func TestIntegration_RedactsContextFields(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithRedaction())
+	ctx := ToContext(context.Background(), logger)
+	AddField(ctx, "authorization", "Bearer secret")
+	AddFields(ctx, map[string]interface{}{"payload": map[string]interface{}{"password": "hunter2"}})
+
+	ExtractEntry(ctx).WithField("session_token", "t-1").Info("request")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %v", len(lines), lines)
+	}
+	for _, secret := range []string{"Bearer secret", "hunter2", "t-1"} {
+		if strings.Contains(lines[0], secret) {
+			t.Errorf("%q should be redacted, got %q", secret, lines[0])
+		}
+	}
+}
+
+func TestIntegration_GCPEncodingWithSpan(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithGCPEncoding("my-project"))
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	ctx = ToContext(ctx, logger)
+
+	if got := ExtractSpanID(ctx); got != "00f067aa0ba902b7" {
+		t.Errorf("ExtractSpanID() = %q, want 00f067aa0ba902b7", got)
+	}
+	ExtractEntry(ctx).Info("in span")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %v", len(lines), lines)
+	}
+	for _, want := range []string{
+		`"logging.googleapis.com/trace":"projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736"`,
+		`"logging.googleapis.com/spanId":"00f067aa0ba902b7"`, `"severity":"INFO"`, `"message":"in span"`,
+	} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("entry should contain %s, got %q", want, lines[0])
+		}
+	}
+}
+
+func TestIntegration_JSONEncodingOmitsSpanID(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t)
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	ctx = ToContext(ctx, logger)
+
+	ExtractEntry(ctx).Info("in span")
+	WithOTEL(ctx).Info("with otel")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %v", len(lines), lines)
+	}
+	for _, line := range lines {
+		if strings.Contains(line, common.KeySpanID) {
+			t.Errorf("json entry should not contain %s, got %q", common.KeySpanID, line)
+		}
+		if !strings.Contains(line, "4bf92f3577b34da6a3ce929d0e0e4736") {
+			t.Errorf("json entry should contain the trace ID, got %q", line)
+		}
+	}
+}
+
+func TestIntegration_MaxFieldsKeepsTopFields(t *testing.T) {
+	common.RegisterTopField("tenant_id")
+	defer common.UnregisterTopField("tenant_id")
+	logger, logFile := logtest.NewFileLogger(t, common.WithMaxFields(2))
+	ctx := ToContext(context.Background(), logger)
+	AddTraceID(ctx, "trace-1")
+	AddTopField(ctx, "tenant_id", "t-1")
+	for i := 0; i < 5; i++ {
+		AddField(ctx, fmt.Sprintf("field_%d", i), i)
+	}
+
+	// The context fields come from maps, log enough entries to see several orders
+	for i := 0; i < 20; i++ {
+		ExtractEntry(ctx).Info("limited")
+	}
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 20 {
+		t.Fatalf("got %d lines, want 20", len(lines))
+	}
+	for _, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v, line %q", err, line)
+		}
+		if entry[common.KeyTraceID] != "trace-1" || entry["tenant_id"] != "t-1" {
+			t.Errorf("trace_id and top fields should be kept, got %v", entry)
+		}
+		if entry[common.KeyTruncatedFields] != float64(3) {
+			t.Errorf("%s = %v, want 3", common.KeyTruncatedFields, entry[common.KeyTruncatedFields])
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Package logtest provides the file loggers tests write through and read
+// back.
+package logtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/zap"
+)
+
+// NewFileLogger returns a JSON logger writing to a file in a temporary
+// directory of t, configured further by withFuncs, and the path of the file
+func NewFileLogger(t testing.TB, withFuncs ...common.WithFunc) (common.Logger, string) {
+	t.Helper()
+	logFile := filepath.Join(t.TempDir(), "test.log")
+	withFuncs = append([]common.WithFunc{common.WithOutputPath(logFile), common.WithJsonEncoding()}, withFuncs...)
+	logger, err := zap.NewLogger(common.Options{}, withFuncs...)
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	return logger, logFile
+}
+
+// ReadLog returns the content of logFile
+func ReadLog(t testing.TB, logFile string) string {
+	t.Helper()
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	return string(content)
+}
+
+// ReadLines returns the lines of logFile, nil when it is empty
+func ReadLines(t testing.TB, logFile string) []string {
+	t.Helper()
+	text := strings.TrimSpace(ReadLog(t, logFile))
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
@@ -0,0 +1,21 @@
+// Package traceid validates the trace IDs the middlewares accept from
+// incoming requests.
+package traceid
+
+// MaxLen is the longest incoming trace ID accepted
+const MaxLen = 64
+
+// Valid reports whether an incoming trace ID can be logged and echoed:
+// non-empty, at most MaxLen bytes of letters, digits and dashes
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,294 @@
+// Package grpc provides gRPC server and client interceptors that set up the
+// glog context logger, log every call and propagate the trace ID.
+package grpc
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gw123/glog"
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/traceid"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	MetadataTraceID   = "x-trace-id"
+	MetadataRequestID = "x-request-id"
+	// MaxTraceIDLen is the longest incoming trace ID accepted
+	MaxTraceIDLen = traceid.MaxLen
+
+	KeyMethod    = "grpc_method"
+	KeyCode      = "grpc_code"
+	KeyPeer      = "peer"
+	KeyRequestID = "request_id"
+	KeyLatencyMs = "latency_ms"
+
+	ServerLogMsg = "grpc server call"
+	ClientLogMsg = "grpc client call"
+)
+
+type Options struct {
+	Logger      common.Logger
+	CodeToLevel func(code codes.Code) common.Level
+	GenerateID  func() string
+}
+
+type WithFunc func(o *Options)
+
+// WithLogger sets the logger injected into every server call context
+func WithLogger(logger common.Logger) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Logger = logger
+	}
+}
+
+// WithCodeToLevel sets the mapping from gRPC status codes to log levels
+func WithCodeToLevel(codeToLevel func(code codes.Code) common.Level) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.CodeToLevel = codeToLevel
+	}
+}
+
+// WithTraceIDGenerator sets the function generating trace IDs for calls
+// that carry none
+func WithTraceIDGenerator(generate func() string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.GenerateID = generate
+	}
+}
+
+// DefaultCodeToLevel maps client caused codes to info, codes worth a look to
+// warn and server failures to error
+func DefaultCodeToLevel(code codes.Code) common.Level {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound,
+		codes.AlreadyExists, codes.Unauthenticated:
+		return common.InfoLevel
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange, codes.Unavailable:
+		return common.WarnLevel
+	default:
+		return common.ErrorLevel
+	}
+}
+
+func newOptions(withFuncs []WithFunc) Options {
+	options := Options{}
+	for _, withFunc := range withFuncs {
+		withFunc(&options)
+	}
+	if options.CodeToLevel == nil {
+		options.CodeToLevel = DefaultCodeToLevel
+	}
+	if options.GenerateID == nil {
+		options.GenerateID = glog.NewTraceID
+	}
+	return options
+}
+
+// UnaryServerInterceptor seeds the context logger from the incoming metadata
+// and logs every unary call
+func UnaryServerInterceptor(withFuncs ...WithFunc) grpc.UnaryServerInterceptor {
+	options := newOptions(withFuncs)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = options.newServerContext(ctx, info.FullMethod)
+		resp, err := handler(ctx, req)
+		options.logCall(ctx, ServerLogMsg, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor seeds the context logger from the incoming metadata
+// and logs every stream when it ends
+func StreamServerInterceptor(withFuncs ...WithFunc) grpc.StreamServerInterceptor {
+	options := newOptions(withFuncs)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := options.newServerContext(ss.Context(), info.FullMethod)
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		options.logCall(ctx, ServerLogMsg, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor propagates the trace ID in the outgoing metadata and
+// logs every unary call. Calls are logged through the context logger of ctx,
+// or Options.Logger when ctx carries none.
+func UnaryClientInterceptor(withFuncs ...WithFunc) grpc.UnaryClientInterceptor {
+	options := newOptions(withFuncs)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		ctx = outgoingContext(ctx)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		options.logCall(ctx, ClientLogMsg, method, start, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor propagates the trace ID in the outgoing metadata and
+// logs every stream when it ends, with its final status
+func StreamClientInterceptor(withFuncs ...WithFunc) grpc.StreamClientInterceptor {
+	options := newOptions(withFuncs)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		ctx = outgoingContext(ctx)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			options.logCall(ctx, ClientLogMsg, method, start, err)
+			return nil, err
+		}
+		return &clientStream{
+			ClientStream:  stream,
+			serverStreams: desc.ServerStreams,
+			finish: func(err error) {
+				options.logCall(ctx, ClientLogMsg, method, start, err)
+			},
+		}, nil
+	}
+}
+
+func (o Options) newServerContext(ctx context.Context, fullMethod string) context.Context {
+	logger := o.Logger
+	if logger == nil {
+		logger = glog.DefaultLogger()
+	}
+	ctx = glog.ToContext(ctx, logger)
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	traceID := firstValue(md, MetadataTraceID)
+	if !traceid.Valid(traceID) {
+		if span := trace.SpanContextFromContext(ctx); span.TraceID().IsValid() {
+			traceID = span.TraceID().String()
+		} else {
+			traceID = o.GenerateID()
+		}
+	}
+	glog.AddTraceID(ctx, traceID)
+	if requestID := firstValue(md, MetadataRequestID); requestID != "" {
+		glog.AddField(ctx, KeyRequestID, requestID)
+	}
+	glog.AddPathname(ctx, fullMethod)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		glog.AddField(ctx, KeyPeer, p.Addr.String())
+	}
+	return ctx
+}
+
+func (o Options) logCall(ctx context.Context, msg, method string, start time.Time, err error) {
+	code := status.Code(err)
+	logger := glog.ExtractEntryOr(ctx, o.Logger).WithFields(map[string]interface{}{
+		KeyMethod:    method,
+		KeyCode:      code.String(),
+		KeyLatencyMs: time.Since(start).Milliseconds(),
+	}).WithError(err)
+
+	switch o.CodeToLevel(code) {
+	case common.DebugLevel:
+		logger.Debug(msg)
+	case common.InfoLevel:
+		logger.Info(msg)
+	case common.WarnLevel:
+		logger.Warn(msg)
+	default:
+		logger.Error(msg)
+	}
+}
+
+// outgoingContext adds the trace ID of ctx to the outgoing metadata
+func outgoingContext(ctx context.Context) context.Context {
+	// Without a context logger the getter panics when glog.IsDebug is set
+	var traceID string
+	if glog.HasContextLogger(ctx) {
+		traceID, _ = glog.TraceIDKey.Get(ctx)
+	}
+	if traceID == "" {
+		if span := trace.SpanContextFromContext(ctx); span.TraceID().IsValid() {
+			traceID = span.TraceID().String()
+		}
+	}
+	if traceID == "" {
+		return ctx
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(MetadataTraceID)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, MetadataTraceID, traceID)
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// serverStream overrides the context of a grpc.ServerStream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// clientStream logs a client stream once, when it ends: RecvMsg returns
+// io.EOF or an error, or the response of a stream without server streaming
+type clientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	once          sync.Once
+	finish        func(err error)
+}
+
+func (s *clientStream) end(err error) {
+	s.once.Do(func() { s.finish(err) })
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	// io.EOF means the stream ended, its status is returned by RecvMsg
+	if err != nil && err != io.EOF {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err != nil {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	case !s.serverStreams:
+		s.end(nil)
+	}
+	return err
+}
@@ -0,0 +1,228 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog"
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	traceIDs chan string
+}
+
+func (s *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.traceIDs <- glog.ExtractTraceID(ctx)
+	if req.Service == "broken" {
+		return nil, status.Error(codes.Internal, "broken service")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	s.traceIDs <- glog.ExtractTraceID(stream.Context())
+	return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+}
+
+func startServer(t *testing.T, serverLogger common.Logger, clientOpts ...WithFunc) (healthpb.HealthClient, *healthServer) {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(WithLogger(serverLogger))),
+		grpc.StreamInterceptor(StreamServerInterceptor(WithLogger(serverLogger))),
+	)
+	hs := &healthServer{traceIDs: make(chan string, 1)}
+	healthpb.RegisterHealthServer(server, hs)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(clientOpts...)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(clientOpts...)),
+	)
+	if err != nil {
+		t.Fatalf("grpc.Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return healthpb.NewHealthClient(conn), hs
+}
+
+func TestUnaryInterceptors_PropagateTraceID(t *testing.T) {
+	serverLogger, serverLogFile := logtest.NewFileLogger(t)
+	client, hs := startServer(t, serverLogger)
+
+	ctx := glog.ToContext(context.Background(), glog.DefaultLogger())
+	glog.AddTraceID(ctx, "grpc-trace-1")
+	ctx = metadata.AppendToOutgoingContext(ctx, MetadataRequestID, "req-1")
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if got := <-hs.traceIDs; got != "grpc-trace-1" {
+		t.Errorf("server trace id = %q, want grpc-trace-1", got)
+	}
+
+	content := logtest.ReadLog(t, serverLogFile)
+	for _, want := range []string{ServerLogMsg, `"grpc_method":"/grpc.health.v1.Health/Check"`, `"grpc_code":"OK"`,
+		`"trace_id":"grpc-trace-1"`, `"request_id":"req-1"`, `"peer":`, `"latency_ms":`, `"level":"[info]"`} {
+		if !strings.Contains(content, want) {
+			t.Errorf("server log should contain %s, got %q", want, content)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor_RejectsInvalidTraceID(t *testing.T) {
+	serverLogger, serverLogFile := logtest.NewFileLogger(t)
+	client, hs := startServer(t, serverLogger)
+
+	for _, traceID := range []string{"bad\"id", "a b", strings.Repeat("a", MaxTraceIDLen+1)} {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), MetadataTraceID, traceID)
+		if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+		if got := <-hs.traceIDs; got == traceID || got == "" {
+			t.Errorf("server trace id = %q, want a generated one for %q", got, traceID)
+		}
+	}
+	if content := logtest.ReadLog(t, serverLogFile); strings.Contains(content, "bad") || strings.Contains(content, "a b") {
+		t.Errorf("server log should not contain the rejected trace ids, got %q", content)
+	}
+}
+
+func TestOutgoingContext_WithoutContextLogger(t *testing.T) {
+	oldIsDebug := glog.IsDebug
+	glog.IsDebug = true
+	defer func() {
+		glog.IsDebug = oldIsDebug
+	}()
+
+	if md, ok := metadata.FromOutgoingContext(outgoingContext(context.Background())); ok {
+		t.Errorf("outgoing metadata = %v, want none without a trace id", md)
+	}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID}))
+	md, _ := metadata.FromOutgoingContext(outgoingContext(ctx))
+	if got := md.Get(MetadataTraceID); len(got) != 1 || got[0] != traceID.String() {
+		t.Errorf("outgoing trace id = %v, want the span trace id", got)
+	}
+}
+
+func TestUnaryServerInterceptor_ErrorLevel(t *testing.T) {
+	serverLogger, serverLogFile := logtest.NewFileLogger(t)
+	client, hs := startServer(t, serverLogger)
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "broken"})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("Check() error = %v, want Internal", err)
+	}
+	if got := <-hs.traceIDs; got == "" {
+		t.Error("server should generate a trace id when none is sent")
+	}
+
+	content := logtest.ReadLog(t, serverLogFile)
+	for _, want := range []string{`"grpc_code":"Internal"`, `"level":"[error]"`, "broken service"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("server log should contain %s, got %q", want, content)
+		}
+	}
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	serverLogger, serverLogFile := logtest.NewFileLogger(t)
+	client, hs := startServer(t, serverLogger)
+
+	ctx := glog.ToContext(context.Background(), glog.DefaultLogger())
+	glog.AddTraceID(ctx, "grpc-stream-trace")
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	if got := <-hs.traceIDs; got != "grpc-stream-trace" {
+		t.Errorf("server trace id = %q, want grpc-stream-trace", got)
+	}
+	stream.Recv()
+
+	content := logtest.ReadLog(t, serverLogFile)
+	if !strings.Contains(content, `"grpc_method":"/grpc.health.v1.Health/Watch"`) {
+		t.Errorf("server log should contain the stream call, got %q", content)
+	}
+}
+
+func TestClientInterceptors_LoggerAndStreamEnd(t *testing.T) {
+	serverLogger, _ := logtest.NewFileLogger(t)
+	clientLogger, clientLogFile := logtest.NewFileLogger(t)
+	client, hs := startServer(t, serverLogger, WithLogger(clientLogger))
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	<-hs.traceIDs
+	if content := logtest.ReadLog(t, clientLogFile); !strings.Contains(content, `"grpc_method":"/grpc.health.v1.Health/Check"`) {
+		t.Errorf("client calls without a context logger should use Options.Logger, got %q", content)
+	}
+
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	<-hs.traceIDs
+	if content := logtest.ReadLog(t, clientLogFile); strings.Contains(content, "Watch") {
+		t.Errorf("the stream should be logged when it ends, got %q", content)
+	}
+	if _, err := stream.Recv(); err == nil {
+		t.Fatal("Recv() should end the stream")
+	}
+	stream.Recv()
+
+	content := logtest.ReadLog(t, clientLogFile)
+	if strings.Count(content, `"grpc_method":"/grpc.health.v1.Health/Watch"`) != 1 || !strings.Contains(content, `"grpc_code":"OK"`) {
+		t.Errorf("the stream end should be logged once with its status, got %q", content)
+	}
+}
+
+func TestDefaultCodeToLevel(t *testing.T) {
+	tests := []struct {
+		code  codes.Code
+		level common.Level
+	}{
+		{codes.OK, common.InfoLevel},
+		{codes.NotFound, common.InfoLevel},
+		{codes.DeadlineExceeded, common.WarnLevel},
+		{codes.Unavailable, common.WarnLevel},
+		{codes.Internal, common.ErrorLevel},
+		{codes.Unknown, common.ErrorLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			if got := DefaultCodeToLevel(tt.code); got != tt.level {
+				t.Errorf("DefaultCodeToLevel(%v) = %v, want %v", tt.code, got, tt.level)
+			}
+		})
+	}
+}
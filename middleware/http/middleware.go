@@ -0,0 +1,274 @@
+// Package http provides a net/http middleware that sets up the glog context
+// logger for every request and writes a structured access log.
+package http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	nethttp "net/http"
+	"strings"
+	"time"
+
+	"github.com/gw123/glog"
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/traceid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	HeaderTraceID       = "X-Trace-Id"
+	HeaderForwardedFor  = "X-Forwarded-For"
+	HeaderRealIP        = "X-Real-Ip"
+	DefaultAccessLogMsg = "http request"
+	// MaxTraceIDLen is the longest incoming trace ID accepted
+	MaxTraceIDLen = traceid.MaxLen
+)
+
+type Options struct {
+	Logger         common.Logger
+	TraceHeader    string
+	TrustedProxies []*net.IPNet
+	GenerateID     func() string
+	DisableAccess  bool
+}
+
+type WithFunc func(o *Options)
+
+// WithLogger sets the logger injected into every request context
+func WithLogger(logger common.Logger) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.Logger = logger
+	}
+}
+
+// WithTraceHeader sets the header used to read and echo the trace ID
+func WithTraceHeader(header string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.TraceHeader = header
+	}
+}
+
+// WithTrustedProxies sets the proxies whose X-Forwarded-For and X-Real-Ip
+// headers are honoured, as CIDRs or plain IPs. Invalid entries are ignored.
+func WithTrustedProxies(proxies ...string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		for _, proxy := range proxies {
+			if !strings.Contains(proxy, "/") {
+				if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+					proxy += "/32"
+				} else {
+					proxy += "/128"
+				}
+			}
+			if _, ipNet, err := net.ParseCIDR(proxy); err == nil {
+				o.TrustedProxies = append(o.TrustedProxies, ipNet)
+			}
+		}
+	}
+}
+
+// WithTraceIDGenerator sets the function generating trace IDs for requests
+// that carry none
+func WithTraceIDGenerator(generate func() string) WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.GenerateID = generate
+	}
+}
+
+// WithoutAccessLog disables the access log entry written after each request
+func WithoutAccessLog() WithFunc {
+	return func(o *Options) {
+		if o == nil {
+			return
+		}
+		o.DisableAccess = true
+	}
+}
+
+// Middleware wraps next so every request gets a context logger carrying the
+// trace ID, pathname and client IP, and writes an access log when it ends.
+// 5xx responses are logged at error level, 4xx at warn and the rest at info.
+func Middleware(next nethttp.Handler, withFuncs ...WithFunc) nethttp.Handler {
+	return New(withFuncs...)(next)
+}
+
+// New returns a middleware constructor configured by withFuncs
+func New(withFuncs ...WithFunc) func(nethttp.Handler) nethttp.Handler {
+	options := Options{}
+	for _, withFunc := range withFuncs {
+		withFunc(&options)
+	}
+	if options.TraceHeader == "" {
+		options.TraceHeader = HeaderTraceID
+	}
+	if options.GenerateID == nil {
+		options.GenerateID = glog.NewTraceID
+	}
+
+	return func(next nethttp.Handler) nethttp.Handler {
+		return nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			start := time.Now()
+			logger := options.Logger
+			if logger == nil {
+				logger = glog.DefaultLogger()
+			}
+
+			ctx := glog.ToContext(r.Context(), logger)
+			traceID := r.Header.Get(options.TraceHeader)
+			if !traceid.Valid(traceID) {
+				if span := trace.SpanContextFromContext(ctx); span.TraceID().IsValid() {
+					traceID = span.TraceID().String()
+				} else {
+					traceID = options.GenerateID()
+				}
+			}
+			glog.AddTraceID(ctx, traceID)
+			glog.AddPathname(ctx, r.URL.Path)
+			glog.AddClientIP(ctx, clientIP(r, options.TrustedProxies))
+			w.Header().Set(options.TraceHeader, traceID)
+
+			rw := &responseWriter{ResponseWriter: w, status: nethttp.StatusOK}
+			// The access log is written even when next panics, before the
+			// panic goes on to the server
+			defer func() {
+				p := recover()
+				if !options.DisableAccess {
+					logAccess(ctx, r, rw, time.Since(start), p)
+				}
+				if p != nil {
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
+// logAccess writes the access log of r. A request whose handler panicked is
+// logged at error level with the panic value, as a 500 unless a status was
+// already written.
+func logAccess(ctx context.Context, r *nethttp.Request, rw *responseWriter, latency time.Duration, p interface{}) {
+	status := rw.status
+	if p != nil && !rw.wroteHeader {
+		status = nethttp.StatusInternalServerError
+	}
+	fields := map[string]interface{}{
+		common.KeyHTTPMethod:    r.Method,
+		common.KeyHTTPStatus:    status,
+		common.KeyHTTPBytes:     rw.bytes,
+		common.KeyHTTPLatencyMs: float64(latency.Microseconds()) / 1000,
+		common.KeyHTTPUserAgent: r.UserAgent(),
+		common.KeyAccessLog:     true,
+	}
+	if p != nil {
+		fields[common.KeyPanic] = fmt.Sprint(p)
+	}
+	entry := glog.ExtractEntry(ctx).WithFields(fields)
+	switch {
+	case p != nil || status >= nethttp.StatusInternalServerError:
+		entry.Error(DefaultAccessLogMsg)
+	case status >= nethttp.StatusBadRequest:
+		entry.Warn(DefaultAccessLogMsg)
+	default:
+		entry.Info(DefaultAccessLogMsg)
+	}
+}
+
+// clientIP returns the client address of r. Forwarding headers are only
+// honoured when the direct peer is a trusted proxy.
+func clientIP(r *nethttp.Request, trusted []*net.IPNet) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !isTrusted(remote, trusted) {
+		return remote
+	}
+
+	if forwarded := r.Header.Get(HeaderForwardedFor); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			if i == 0 || !isTrusted(hop, trusted) {
+				return hop
+			}
+		}
+	}
+	if realIP := strings.TrimSpace(r.Header.Get(HeaderRealIP)); realIP != "" {
+		return realIP
+	}
+	return remote
+}
+
+func isTrusted(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// responseWriter records the status code and body size of a response
+type responseWriter struct {
+	nethttp.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(nethttp.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(nethttp.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("glog: response writer does not implement http.Hijacker")
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the wrapped ResponseWriter for http.ResponseController
+func (w *responseWriter) Unwrap() nethttp.ResponseWriter {
+	return w.ResponseWriter
+}
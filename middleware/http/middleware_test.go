@@ -0,0 +1,192 @@
+package http
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gw123/glog"
+	"github.com/gw123/glog/internal/logtest"
+)
+
+func TestMiddleware_ContextAndAccessLog(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t)
+
+	var traceID, pathname, ip string
+	handler := Middleware(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		traceID = glog.ExtractTraceID(r.Context())
+		pathname = glog.ExtractPathname(r.Context())
+		ip = glog.ExtractClientIP(r.Context())
+		w.WriteHeader(nethttp.StatusCreated)
+		w.Write([]byte("hello"))
+	}), WithLogger(logger))
+
+	req := httptest.NewRequest(nethttp.MethodPost, "/orders", nil)
+	req.RemoteAddr = "192.0.2.10:5555"
+	req.Header.Set(HeaderTraceID, "trace-from-header")
+	req.Header.Set("User-Agent", "test-agent")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if traceID != "trace-from-header" || pathname != "/orders" || ip != "192.0.2.10" {
+		t.Errorf("context = %q %q %q, want trace-from-header /orders 192.0.2.10", traceID, pathname, ip)
+	}
+	if rec.Header().Get(HeaderTraceID) != "trace-from-header" {
+		t.Errorf("response trace header = %q, want trace-from-header", rec.Header().Get(HeaderTraceID))
+	}
+
+	content := logtest.ReadLog(t, logFile)
+	for _, want := range []string{`"method":"POST"`, `"status":201`, `"bytes":5`, `"latency_ms":`,
+		`"user_agent":"test-agent"`, `"trace_id":"trace-from-header"`, `"level":"[info]"`, `"access_log":true`} {
+		if !strings.Contains(content, want) {
+			t.Errorf("access log should contain %s, got %q", want, content)
+		}
+	}
+}
+
+func TestMiddleware_GeneratesTraceID(t *testing.T) {
+	logger, _ := logtest.NewFileLogger(t)
+
+	var traceID string
+	handler := Middleware(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		traceID = glog.ExtractTraceID(r.Context())
+	}), WithLogger(logger), WithTraceIDGenerator(func() string { return "generated" }))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/", nil))
+
+	if traceID != "generated" || rec.Header().Get(HeaderTraceID) != "generated" {
+		t.Errorf("trace id = %q, header = %q, want generated", traceID, rec.Header().Get(HeaderTraceID))
+	}
+}
+
+func TestMiddleware_RejectsInvalidTraceID(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t)
+	handler := Middleware(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}),
+		WithLogger(logger), WithTraceIDGenerator(func() string { return "generated" }))
+
+	for _, header := range []string{"bad\"id", "a b", strings.Repeat("a", MaxTraceIDLen+1), "é"} {
+		req := httptest.NewRequest(nethttp.MethodGet, "/", nil)
+		req.Header.Set(HeaderTraceID, header)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got := rec.Header().Get(HeaderTraceID); got != "generated" {
+			t.Errorf("trace header %q: echoed %q, want a generated ID", header, got)
+		}
+	}
+	if content := logtest.ReadLog(t, logFile); strings.Contains(content, "bad") || strings.Contains(content, "aaaa") {
+		t.Errorf("invalid trace IDs should not be logged, got %q", content)
+	}
+}
+
+func TestMiddleware_AccessLogOnPanic(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t)
+	handler := Middleware(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		panic("handler exploded")
+	}), WithLogger(logger))
+
+	func() {
+		defer func() {
+			if p := recover(); p != "handler exploded" {
+				t.Errorf("recover() = %v, want the handler panic to propagate", p)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(nethttp.MethodGet, "/boom", nil))
+	}()
+
+	content := logtest.ReadLog(t, logFile)
+	for _, want := range []string{`"level":"[error]"`, `"status":500`, `"panic":"handler exploded"`, `"pathname":"/boom"`} {
+		if !strings.Contains(content, want) {
+			t.Errorf("access log should contain %s, got %q", want, content)
+		}
+	}
+}
+
+func TestMiddleware_LevelByStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		level  string
+	}{
+		{nethttp.StatusOK, "[info]"},
+		{nethttp.StatusNotFound, "[warn]"},
+		{nethttp.StatusBadGateway, "[error]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(nethttp.StatusText(tt.status), func(t *testing.T) {
+			logger, logFile := logtest.NewFileLogger(t)
+			handler := Middleware(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				w.WriteHeader(tt.status)
+			}), WithLogger(logger))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(nethttp.MethodGet, "/", nil))
+
+			if content := logtest.ReadLog(t, logFile); !strings.Contains(content, `"level":"`+tt.level+`"`) {
+				t.Errorf("access log level should be %s, got %q", tt.level, content)
+			}
+		})
+	}
+}
+
+func TestLogAccess_FractionalLatency(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t)
+	ctx := glog.ToContext(context.Background(), logger)
+	rw := &responseWriter{status: nethttp.StatusOK}
+	logAccess(ctx, httptest.NewRequest(nethttp.MethodGet, "/", nil), rw, 250*time.Microsecond, nil)
+
+	if content := logtest.ReadLog(t, logFile); !strings.Contains(content, `"latency_ms":0.25`) {
+		t.Errorf("access log should keep the fraction of a millisecond, got %q", content)
+	}
+}
+
+func TestMiddleware_WithoutAccessLog(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t)
+	handler := Middleware(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {}),
+		WithLogger(logger), WithoutAccessLog())
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(nethttp.MethodGet, "/", nil))
+
+	if content := logtest.ReadLog(t, logFile); content != "" {
+		t.Errorf("no access log expected, got %q", content)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	var options Options
+	WithTrustedProxies("10.0.0.0/8", "192.0.2.1", "invalid")(&options)
+	if len(options.TrustedProxies) != 2 {
+		t.Fatalf("TrustedProxies = %v, want 2 entries", options.TrustedProxies)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		want       string
+	}{
+		{"untrusted peer ignores headers", "198.51.100.7:1234", "203.0.113.5", "203.0.113.6", "198.51.100.7"},
+		{"trusted peer uses forwarded", "10.1.2.3:1234", "203.0.113.5", "", "203.0.113.5"},
+		{"skips trusted hops", "10.1.2.3:1234", "203.0.113.5, 10.9.9.9, 192.0.2.1", "", "203.0.113.5"},
+		{"stops at first untrusted hop", "10.1.2.3:1234", "203.0.113.5, 198.51.100.1, 10.9.9.9", "", "198.51.100.1"},
+		{"trusted peer uses real ip", "192.0.2.1:80", "", "203.0.113.9", "203.0.113.9"},
+		{"trusted peer without headers", "10.1.2.3:1234", "", "", "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(nethttp.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set(HeaderForwardedFor, tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set(HeaderRealIP, tt.realIP)
+			}
+			if got := clientIP(req, options.TrustedProxies); got != tt.want {
+				t.Errorf("clientIP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
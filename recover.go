@@ -0,0 +1,157 @@
+package glog
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/gw123/glog/common"
+)
+
+// DefaultPanicMsg is the message Recover and SafeGo log a panic with
+const DefaultPanicMsg = "panic recovered"
+
+// PanicError is the error a recovered panic is converted to
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value when it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+type RecoverOptions struct {
+	// Repanic panics again with the original value after logging
+	Repanic bool
+	// Err receives a *PanicError for the recovered panic
+	Err *error
+	// Msg is the log message, DefaultPanicMsg when empty
+	Msg string
+}
+
+type RecoverFunc func(o *RecoverOptions)
+
+// WithRepanic panics again with the original value after logging
+func WithRepanic() RecoverFunc {
+	return func(o *RecoverOptions) {
+		if o == nil {
+			return
+		}
+		o.Repanic = true
+	}
+}
+
+// WithPanicError stores the recovered panic as a *PanicError in err, for
+// functions with a named error result
+func WithPanicError(err *error) RecoverFunc {
+	return func(o *RecoverOptions) {
+		if o == nil {
+			return
+		}
+		o.Err = err
+	}
+}
+
+// WithPanicMsg sets the message the panic is logged with
+func WithPanicMsg(msg string) RecoverFunc {
+	return func(o *RecoverOptions) {
+		if o == nil {
+			return
+		}
+		o.Msg = msg
+	}
+}
+
+// Recover must be deferred directly. It recovers a panic and logs the panic
+// value and goroutine stack at error level with the fields of ctx, reporting
+// the panic site as caller. The panic is then swallowed, converted to an
+// error with WithPanicError or raised again with WithRepanic.
+//
+//	func handle(ctx context.Context) (err error) {
+//		defer glog.Recover(ctx, glog.WithPanicError(&err))
+//		...
+//	}
+func Recover(ctx context.Context, withFuncs ...RecoverFunc) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	handlePanic(ctx, r, panicDepth(), withFuncs)
+}
+
+// SafeGo runs fn in a new goroutine with ctx. A panic in fn is recovered and
+// logged like Recover does. The returned channel receives the error of fn,
+// or a *PanicError, and is closed once fn has returned.
+func SafeGo(ctx context.Context, fn func(ctx context.Context) error, withFuncs ...RecoverFunc) <-chan error {
+	errc := make(chan error, 1)
+	var err error
+	goRecovered(func() {
+		err = fn(ctx)
+	}, func(r interface{}, depth int) {
+		handlePanic(ctx, r, depth, append([]RecoverFunc{WithPanicError(&err)}, withFuncs...))
+	}, func() {
+		errc <- err
+		close(errc)
+	})
+	return errc
+}
+
+// handlePanic must be called by the deferred function that recovered r, with
+// the number of frames between that function and the panic site
+func handlePanic(ctx context.Context, r interface{}, depth int, withFuncs []RecoverFunc) {
+	options := RecoverOptions{Msg: DefaultPanicMsg}
+	for _, withFunc := range withFuncs {
+		withFunc(&options)
+	}
+
+	stack := debug.Stack()
+	// logPanic, handlePanic and the deferred function
+	logger := common.AddCallerSkip(ExtractEntry(ctx), 3+depth)
+	logPanic(logger, r, stack, options.Msg)
+
+	if options.Err != nil {
+		*options.Err = &PanicError{Value: r, Stack: stack}
+	}
+	if options.Repanic {
+		panic(r)
+	}
+}
+
+// logPanic logs r with the stack from the panic site in the stack key of the
+// logger, or with stack as a field when the logger can not capture stacks
+func logPanic(logger common.Logger, r interface{}, stack []byte, msg string) {
+	logger = logger.WithField(common.KeyPanic, fmt.Sprint(r))
+	if _, ok := logger.(common.StackLogger); ok {
+		common.AddStack(logger).Error(msg)
+		return
+	}
+	logger.WithField(common.KeyStacktrace, string(stack)).Error(msg)
+}
+
+// panicDepth counts the runtime frames between the deferred function calling
+// it and the function that panicked
+func panicDepth() int {
+	var pcs [16]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	depth := 0
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			return depth
+		}
+		depth++
+		if !more {
+			return depth
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package glog
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+)
+
+func TestRecover_ConvertsToError(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+	AddTraceID(ctx, "recover-trace")
+
+	var line int
+	handle := func() (err error) {
+		defer Recover(ctx, WithPanicError(&err))
+		_, _, line, _ = runtime.Caller(0)
+		panic("handler exploded")
+	}
+
+	err := handle()
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "handler exploded" || len(panicErr.Stack) == 0 {
+		t.Fatalf("Recover() should store a *PanicError, got %v", err)
+	}
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %v", len(lines), lines)
+	}
+	for _, want := range []string{`"level":"[error]"`, `"msg":"panic recovered"`, `"panic":"handler exploded"`,
+		`"stacktrace":"github.com/gw123/glog.TestRecover_ConvertsToError.func1\n`, `"trace_id":"recover-trace"`, "recover_test.go:" + strconv.Itoa(line+1)} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("panic log should contain %s, got %q", want, lines[0])
+		}
+	}
+}
+
+func TestRecover_StackInEntryStackKey(t *testing.T) {
+	tests := []struct {
+		withFunc common.WithFunc
+		key      string
+	}{
+		{common.WithStacktraceLevel(common.ErrorLevel), common.KeyStacktrace},
+		{common.WithJSONKeys(common.JSONKeys{Stacktrace: "stack"}), "stack"},
+	}
+	for _, tt := range tests {
+		logger, logFile := logtest.NewFileLogger(t, common.WithStacktraceLevel(common.ErrorLevel), tt.withFunc)
+		ctx := ToContext(context.Background(), logger)
+
+		func() {
+			defer Recover(ctx)
+			panic("exploded")
+		}()
+
+		line := logtest.ReadLog(t, logFile)
+		if n := strings.Count(line, `"stack`); n != 1 ||
+			!strings.Contains(line, `"`+tt.key+`":"github.com/gw123/glog.TestRecover_StackInEntryStackKey.func1\n`) {
+			t.Errorf("the panic stack should be the only stack key, %s, got %q", tt.key, line)
+		}
+	}
+}
+
+func TestRecover_Repanic(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+
+	defer func() {
+		if r := recover(); r != "again" {
+			t.Errorf("Recover() should panic again with the original value, got %v", r)
+		}
+		if lines := logtest.ReadLines(t, logFile); len(lines) != 1 {
+			t.Errorf("the panic should be logged once before re-panicking, got %v", lines)
+		}
+	}()
+
+	func() {
+		defer Recover(ctx, WithRepanic())
+		panic("again")
+	}()
+}
+
+func TestRecover_RuntimePanic(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+
+	var line int
+	func() {
+		defer Recover(ctx, WithPanicMsg("nil map"))
+		var m map[string]int
+		_, _, line, _ = runtime.Caller(0)
+		m["x"] = 1
+	}()
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"msg":"nil map"`) ||
+		!strings.Contains(lines[0], "recover_test.go:"+strconv.Itoa(line+1)) {
+		t.Errorf("runtime panics should be logged at the faulting line, got %v", lines)
+	}
+}
+
+func TestRecover_NoPanic(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+
+	func() {
+		defer Recover(ctx)
+	}()
+	if lines := logtest.ReadLines(t, logFile); len(lines) != 0 {
+		t.Errorf("nothing should be logged without a panic, got %v", lines)
+	}
+}
+
+func TestSafeGo(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), logger)
+	AddField(ctx, "job", "sync")
+
+	errBoom := errors.New("boom")
+	if err := <-SafeGo(ctx, func(ctx context.Context) error { return errBoom }); err != errBoom {
+		t.Errorf("SafeGo() should return the error of fn, got %v", err)
+	}
+
+	err := <-SafeGo(ctx, func(ctx context.Context) error { panic(errBoom) })
+	if !errors.Is(err, errBoom) {
+		t.Errorf("SafeGo() should return a *PanicError wrapping the panic value, got %v", err)
+	}
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"job":"sync"`) || !strings.Contains(lines[0], `"panic":"boom"`) {
+		t.Errorf("the panic should be logged with the ctx fields, got %v", lines)
+	}
+}
@@ -0,0 +1,186 @@
+package glog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+
+	"github.com/gw123/glog/common"
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// desugarer is implemented by loggers backed by a zap logger
+type desugarer interface {
+	Desugar() *uberzap.Logger
+}
+
+// SlogHandler is a slog.Handler writing through the zap core of a glog
+// logger, so slog records share the format, sinks, trace_id slot and context
+// fields of glog entries
+type SlogHandler struct {
+	logger common.Logger
+	fields []zapcore.Field
+}
+
+// NewSlogHandler returns a slog.Handler writing through logger. A nil logger
+// uses the default logger at the time of each record.
+func NewSlogHandler(logger common.Logger) *SlogHandler {
+	return &SlogHandler{logger: logger}
+}
+
+// Enabled reports whether the underlying logger writes entries at level
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	zl := h.zapLogger(h.baseLogger())
+	if zl == nil {
+		return true
+	}
+	return zl.Core().Enabled(slogToZapLevel(level))
+}
+
+// Handle writes the record through the handler logger, with the fields of
+// the context logger in ctx
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	logger := h.baseLogger()
+	if ctx != nil {
+		logger = extractEntry(ctx, logger, true)
+	}
+
+	zl := h.zapLogger(logger)
+	if zl == nil {
+		return fmt.Errorf("glog: slog handler logger %T is not backed by zap", logger)
+	}
+	if len(h.fields) > 0 {
+		zl = zl.With(h.fields...)
+	}
+
+	ce := zl.Check(slogToZapLevel(record.Level), record.Message)
+	if ce == nil {
+		return nil
+	}
+	if !record.Time.IsZero() {
+		ce.Entry.Time = record.Time
+	}
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		ce.Entry.Caller = zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true)
+	}
+
+	fields := make([]zapcore.Field, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, attr)
+		return true
+	})
+	ce.Write(fields...)
+	return nil
+}
+
+// WithAttrs returns a handler that adds attrs to every record
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	fields := make([]zapcore.Field, len(h.fields), len(h.fields)+len(attrs))
+	copy(fields, h.fields)
+	for _, attr := range attrs {
+		fields = appendAttr(fields, attr)
+	}
+	return &SlogHandler{logger: h.logger, fields: fields}
+}
+
+// WithGroup returns a handler that nests the attrs added afterwards under name
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	fields := make([]zapcore.Field, len(h.fields), len(h.fields)+1)
+	copy(fields, h.fields)
+	return &SlogHandler{logger: h.logger, fields: append(fields, uberzap.Namespace(name))}
+}
+
+func (h *SlogHandler) baseLogger() common.Logger {
+	if h.logger != nil {
+		return h.logger
+	}
+	return DefaultLogger()
+}
+
+func (h *SlogHandler) zapLogger(logger common.Logger) *uberzap.Logger {
+	d, ok := logger.(desugarer)
+	if !ok {
+		return nil
+	}
+	return d.Desugar()
+}
+
+func slogToZapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return zapcore.DebugLevel
+	case level < slog.LevelWarn:
+		return zapcore.InfoLevel
+	case level < slog.LevelError:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// appendAttr converts attr to zap fields following the slog handler rules:
+// empty attrs are ignored and groups without a key are inlined
+func appendAttr(fields []zapcore.Field, attr slog.Attr) []zapcore.Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	value := attr.Value
+	switch value.Kind() {
+	case slog.KindGroup:
+		group := value.Group()
+		if len(group) == 0 {
+			return fields
+		}
+		if attr.Key == "" {
+			for _, a := range group {
+				fields = appendAttr(fields, a)
+			}
+			return fields
+		}
+		return append(fields, uberzap.Object(attr.Key, slogGroup(group)))
+	case slog.KindString:
+		return append(fields, uberzap.String(attr.Key, value.String()))
+	case slog.KindInt64:
+		return append(fields, uberzap.Int64(attr.Key, value.Int64()))
+	case slog.KindUint64:
+		return append(fields, uberzap.Uint64(attr.Key, value.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, uberzap.Float64(attr.Key, value.Float64()))
+	case slog.KindBool:
+		return append(fields, uberzap.Bool(attr.Key, value.Bool()))
+	case slog.KindDuration:
+		return append(fields, uberzap.Duration(attr.Key, value.Duration()))
+	case slog.KindTime:
+		return append(fields, uberzap.Time(attr.Key, value.Time()))
+	default:
+		if err, ok := value.Any().(error); ok {
+			return append(fields, uberzap.NamedError(attr.Key, err))
+		}
+		return append(fields, uberzap.Any(attr.Key, value.Any()))
+	}
+}
+
+// slogGroup encodes a slog group as a nested object
+type slogGroup []slog.Attr
+
+func (g slogGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	var fields []zapcore.Field
+	for _, attr := range g {
+		fields = appendAttr(fields, attr)
+	}
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package glog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSlogHandler_Attrs(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	sl := slog.New(NewSlogHandler(logger))
+
+	sl.Info("slog attrs", "str", "v", "int", 7, "bool", true, "dur", time.Second,
+		slog.Group("req", "id", "r-1"), "err", errors.New("boom"))
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %v", len(lines), lines)
+	}
+	for _, want := range []string{`"msg":"slog attrs"`, `"str":"v"`, `"int":7`, `"bool":true`,
+		`"req":{"id":"r-1"}`, `"err":"boom"`, "slog_test.go"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("log should contain %s, got %q", want, lines[0])
+		}
+	}
+}
+
+func TestSlogHandler_WithAttrsAndGroup(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	sl := slog.New(NewSlogHandler(logger)).With("service", "api").WithGroup("http").With("method", "GET")
+
+	sl.Info("grouped", "status", 200)
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %v", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], `"service":"api","http":{"method":"GET","status":200}`) {
+		t.Errorf("attrs after WithGroup should be nested, got %q", lines[0])
+	}
+}
+
+func TestSlogHandler_ContextFields(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctxLogger, ctxLogFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	ctx := ToContext(context.Background(), ctxLogger)
+	AddTraceID(ctx, "slog-trace")
+	AddField(ctx, "tenant", "acme")
+
+	slog.New(NewSlogHandler(logger)).InfoContext(ctx, "with ctx")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 {
+		t.Fatalf("the handler logger should be used, got %v", lines)
+	}
+	for _, want := range []string{`"trace_id":"slog-trace"`, `"tenant":"acme"`} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("log should contain %s, got %q", want, lines[0])
+		}
+	}
+	if lines := logtest.ReadLines(t, ctxLogFile); len(lines) != 0 {
+		t.Errorf("the context logger should only contribute fields, got %v", lines)
+	}
+}
+
+func TestSlogHandler_DebugWithoutContextLogger(t *testing.T) {
+	oldIsDebug := IsDebug
+	IsDebug = true
+	defer func() {
+		IsDebug = oldIsDebug
+	}()
+	logger, logFile := logtest.NewFileLogger(t)
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanCtx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID}))
+
+	sl := slog.New(NewSlogHandler(logger))
+	sl.InfoContext(context.Background(), "no ctx logger")
+	sl.InfoContext(spanCtx, "span only")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %v", len(lines), lines)
+	}
+	if want := `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`; !strings.Contains(lines[1], want) {
+		t.Errorf("log should contain %s, got %q", want, lines[1])
+	}
+}
+
+func TestSlogHandler_NotZapBacked(t *testing.T) {
+	h := NewSlogHandler(struct{ common.Logger }{})
+	record := slog.NewRecord(time.Now(), slog.LevelInfo, "dropped", 0)
+	if err := h.Handle(context.Background(), record); err == nil {
+		t.Error("Handle() should fail for a logger not backed by zap")
+	}
+}
+
+func TestSlogHandler_Enabled(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.WarnLevel))
+	h := NewSlogHandler(logger)
+
+	if h.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("Enabled(info) = true for a warn logger")
+	}
+	if !h.Enabled(context.Background(), slog.LevelError) {
+		t.Error("Enabled(error) = false for a warn logger")
+	}
+
+	slog.New(h).Info("dropped")
+	slog.New(h).Warn("kept")
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], "kept") {
+		t.Errorf("only the warn entry should be written, got %v", lines)
+	}
+}
+
+func TestSlogToZapLevel(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  string
+	}{
+		{slog.LevelDebug, "debug"},
+		{slog.LevelDebug + 2, "debug"},
+		{slog.LevelInfo, "info"},
+		{slog.LevelWarn, "warn"},
+		{slog.LevelError, "error"},
+		{slog.LevelError + 4, "error"},
+	}
+
+	for _, tt := range tests {
+		if got := slogToZapLevel(tt.level).String(); got != tt.want {
+			t.Errorf("slogToZapLevel(%v) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package glog
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gw123/glog/common"
+)
+
+// TopKey is a typed key for a top field stored in the context logger
+type TopKey[T any] struct {
+	name string
+}
+
+var (
+	TraceIDKey  = TopKey[string]{name: common.KeyTraceID}
+	UserIDKey   = TopKey[int64]{name: common.KeyUserID}
+	PathnameKey = TopKey[string]{name: common.KeyPathname}
+	ClientIPKey = TopKey[string]{name: common.KeyClientIP}
+)
+
+// NewTopKey creates a typed top field key and registers it with the encoders,
+// so its value is rendered in a fixed position like trace_id
+func NewTopKey[T any](name string) TopKey[T] {
+	common.RegisterTopField(name)
+	return TopKey[T]{name: name}
+}
+
+// Name returns the log field name of the key
+func (k TopKey[T]) Name() string {
+	return k.name
+}
+
+// Set stores v as a top field of the context logger
+func (k TopKey[T]) Set(ctx context.Context, v T) {
+	AddTopField(ctx, k.name, v)
+}
+
+// Get returns the value stored for the key and whether it was set
+func (k TopKey[T]) Get(ctx context.Context) (T, bool) {
+	var zero T
+	l, ok := ctx.Value(ctxLoggerKey).(*ctxLogger)
+	if !ok || l == nil {
+		if IsDebug {
+			panic(errors.New("not set ctxLogger"))
+		}
+		return zero, false
+	}
+
+	l.mutex.RLock()
+	val, ok := l.topFields[k.name].(T)
+	l.mutex.RUnlock()
+	if !ok {
+		return zero, false
+	}
+	return val, true
+}
@@ -0,0 +1,69 @@
+package glog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gw123/glog/common"
+)
+
+func TestTopKey_SetGet(t *testing.T) {
+	tenantKey := NewTopKey[string]("tenant_id")
+	defer common.UnregisterTopField("tenant_id")
+
+	if tenantKey.Name() != "tenant_id" {
+		t.Errorf("Name() = %v, want tenant_id", tenantKey.Name())
+	}
+	if !common.IsTopField("tenant_id") {
+		t.Error("NewTopKey() should register the top field")
+	}
+
+	ctx := ToContext(context.Background(), DefaultLogger())
+	if _, ok := tenantKey.Get(ctx); ok {
+		t.Error("Get() before Set() should return false")
+	}
+
+	tenantKey.Set(ctx, "acme")
+	val, ok := tenantKey.Get(ctx)
+	if !ok || val != "acme" {
+		t.Errorf("Get() = %v, %v, want acme, true", val, ok)
+	}
+}
+
+func TestTopKey_TypeMismatch(t *testing.T) {
+	ctx := ToContext(context.Background(), DefaultLogger())
+	AddTopField(ctx, "session_id", 42)
+
+	sessionKey := TopKey[string]{name: "session_id"}
+	if val, ok := sessionKey.Get(ctx); ok {
+		t.Errorf("Get() with mismatched type = %v, true, want false", val)
+	}
+}
+
+func TestTopKey_NoContextLogger(t *testing.T) {
+	key := TopKey[int64]{name: "count"}
+	key.Set(context.Background(), 1)
+	if _, ok := key.Get(context.Background()); ok {
+		t.Error("Get() without context logger should return false")
+	}
+}
+
+func TestTopKey_BuiltinKeys(t *testing.T) {
+	ctx := ToContext(context.Background(), DefaultLogger())
+	AddUserID(ctx, 7)
+	AddPathname(ctx, "/orders")
+	AddClientIP(ctx, "10.0.0.1")
+
+	if val, ok := UserIDKey.Get(ctx); !ok || val != 7 {
+		t.Errorf("UserIDKey.Get() = %v, %v, want 7, true", val, ok)
+	}
+	if val, ok := PathnameKey.Get(ctx); !ok || val != "/orders" {
+		t.Errorf("PathnameKey.Get() = %v, %v, want /orders, true", val, ok)
+	}
+	if ExtractClientIP(ctx) != "10.0.0.1" {
+		t.Errorf("ExtractClientIP() = %v, want 10.0.0.1", ExtractClientIP(ctx))
+	}
+	if common.IsTopField(common.KeyUserID) {
+		t.Error("built-in keys should not be registered for fixed positions")
+	}
+}
@@ -0,0 +1,157 @@
+package glog
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/gw123/glog/common"
+)
+
+// writerFrames is the number of glog frames between Writer.Write and the
+// logger call: Write, writeLine and logAt
+const writerFrames = 3
+
+// Writer is an io.Writer that logs every line written to it as one entry
+type Writer struct {
+	logger       common.Logger
+	level        common.Level
+	skipPrefixes []string
+	buf          []byte
+	mutex        sync.Mutex
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// NewWriter returns an io.Writer logging each line at level through logger,
+// for sinks like http.Server.ErrorLog or exec.Cmd.Stderr. A nil logger uses
+// the default logger. Levels above error are logged at error level so a
+// writer never exits or panics. Call Close to log a trailing partial line.
+func NewWriter(logger common.Logger, level common.Level) *Writer {
+	return &Writer{logger: logger, level: level}
+}
+
+// NewStdLog returns a standard library *log.Logger writing through logger,
+// reporting the caller of the log package functions
+func NewStdLog(logger common.Logger, level common.Level) *log.Logger {
+	return log.New(newStdLogWriter(logger, level), "", 0)
+}
+
+// RedirectStdLog sends the output of the standard library log package to the
+// default logger at level, and returns a func restoring the previous output
+func RedirectStdLog(level common.Level) func() {
+	flags, prefix, writer := log.Flags(), log.Prefix(), log.Writer()
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(newStdLogWriter(nil, level))
+
+	return func() {
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+		log.SetOutput(writer)
+	}
+}
+
+func newStdLogWriter(logger common.Logger, level common.Level) *Writer {
+	w := NewWriter(logger, level)
+	w.skipPrefixes = []string{"log."}
+	return w
+}
+
+// Write logs every complete line in p and keeps a trailing partial line
+// until the next write
+func (w *Writer) Write(p []byte) (int, error) {
+	skip := w.callerDepth()
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(w.buf[:i], skip)
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// Close logs a buffered partial line, if any
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.buf) > 0 {
+		w.writeLine(w.buf, 0)
+		w.buf = nil
+	}
+	return nil
+}
+
+func (w *Writer) writeLine(line []byte, skip int) {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) == 0 {
+		return
+	}
+
+	logger := w.logger
+	if logger == nil {
+		logger = DefaultLogger()
+	}
+	logAt(common.AddCallerSkip(logger, writerFrames+skip), w.level, string(line))
+}
+
+// callerDepth counts the frames above Write that belong to packages whose
+// callers should be reported instead, such as the standard log package
+func (w *Writer) callerDepth() int {
+	if len(w.skipPrefixes) == 0 {
+		return 0
+	}
+
+	var pcs [16]uintptr
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	depth := 0
+	for {
+		frame, more := frames.Next()
+		if !w.skipped(frame.Function) {
+			break
+		}
+		depth++
+		if !more {
+			break
+		}
+	}
+	return depth
+}
+
+func (w *Writer) skipped(function string) bool {
+	for _, prefix := range w.skipPrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// logAt logs msg at level, capping levels above error at error
+func logAt(logger common.Logger, level common.Level, msg string) {
+	switch {
+	case level <= common.DebugLevel:
+		logger.Debug(msg)
+	case level == common.InfoLevel:
+		logger.Info(msg)
+	case level == common.WarnLevel:
+		logger.Warn(msg)
+	default:
+		logger.Error(msg)
+	}
+}
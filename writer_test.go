@@ -0,0 +1,115 @@
+package glog
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+	"github.com/gw123/glog/internal/logtest"
+)
+
+func TestWriter_SplitsLines(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	w := NewWriter(logger, common.WarnLevel)
+
+	w.Write([]byte("first line\nsecond "))
+	w.Write([]byte("line\r\n\npartial"))
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %v", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], `"msg":"first line"`) || !strings.Contains(lines[1], `"msg":"second line"`) {
+		t.Errorf("lines should be split on newlines, got %v", lines)
+	}
+	if !strings.Contains(lines[0], `"level":"[warn]"`) {
+		t.Errorf("lines should be logged at the writer level, got %q", lines[0])
+	}
+
+	w.Close()
+	lines = logtest.ReadLines(t, logFile)
+	if len(lines) != 3 || !strings.Contains(lines[2], `"msg":"partial"`) {
+		t.Errorf("Close() should log the partial line, got %v", lines)
+	}
+}
+
+func TestWriter_CapsLevel(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	NewWriter(logger, common.FatalLevel).Write([]byte("not fatal\n"))
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"level":"[error]"`) {
+		t.Errorf("levels above error should be capped at error, got %v", lines)
+	}
+}
+
+func TestWriter_Caller(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	w := NewWriter(logger, common.InfoLevel)
+
+	_, _, line, _ := runtime.Caller(0)
+	w.Write([]byte("direct write\n"))
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 || !strings.Contains(lines[0], "writer_test.go:"+strconv.Itoa(line+1)) {
+		t.Errorf("caller should be the Write call site, got %v", lines)
+	}
+}
+
+func TestNewStdLog_Caller(t *testing.T) {
+	logger, logFile := logtest.NewFileLogger(t, common.WithLevel(common.InfoLevel))
+	stdLogger := NewStdLog(logger, common.ErrorLevel)
+
+	_, _, line, _ := runtime.Caller(0)
+	stdLogger.Printf("http: TLS handshake error from %s", "10.0.0.1")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %v", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], "writer_test.go:"+strconv.Itoa(line+1)) {
+		t.Errorf("caller should be the log.Printf call site, got %q", lines[0])
+	}
+	if !strings.Contains(lines[0], `"msg":"http: TLS handshake error from 10.0.0.1"`) {
+		t.Errorf("message should be the formatted line, got %q", lines[0])
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "stdlog.log")
+	err := SetDefaultLoggerConfig(common.Options{}, common.WithOutputPath(logFile), common.WithJsonEncoding())
+	if err != nil {
+		t.Fatalf("SetDefaultLoggerConfig() error = %v", err)
+	}
+	defer SetDefaultLoggerConfig(common.Options{}, common.WithConsoleEncoding(), common.WithStdoutOutputPath(), common.WithStderrErrorOutputPath())
+
+	var previous bytes.Buffer
+	log.SetOutput(&previous)
+	log.SetFlags(log.LstdFlags)
+
+	restore := RedirectStdLog(common.WarnLevel)
+	_, _, line, _ := runtime.Caller(0)
+	log.Println("from std log")
+	restore()
+	log.Println("after restore")
+
+	lines := logtest.ReadLines(t, logFile)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1: %v", len(lines), lines)
+	}
+	for _, want := range []string{`"msg":"from std log"`, `"level":"[warn]"`, "writer_test.go:" + strconv.Itoa(line+1)} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("redirected log should contain %s, got %q", want, lines[0])
+		}
+	}
+	if !strings.Contains(previous.String(), "after restore") || log.Flags() != log.LstdFlags {
+		t.Errorf("restore should reset the previous output and flags, got %q", previous.String())
+	}
+	log.SetOutput(os.Stderr)
+}
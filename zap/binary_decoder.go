@@ -0,0 +1,523 @@
+package zap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gw123/glog/common"
+)
+
+// maxBinaryDepth bounds the nesting of decoded maps and arrays
+const maxBinaryDepth = 512
+
+var errTruncatedRecord = errors.New("truncated binary record")
+
+// ReadBinaryFrame reads the next record written by the msgpack or cbor
+// encoding from r, without its length prefix. It returns io.EOF when r ends
+// between records.
+func ReadBinaryFrame(r io.Reader) ([]byte, error) {
+	var header [frameHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errTruncatedRecord
+		}
+		return nil, err
+	}
+	// The record is copied as it arrives, so a corrupt length does not
+	// allocate gigabytes up front
+	var record bytes.Buffer
+	n := int64(binary.BigEndian.Uint32(header[:]))
+	if _, err := io.CopyN(&record, r, n); err != nil {
+		if err == io.EOF {
+			return nil, errTruncatedRecord
+		}
+		return nil, err
+	}
+	return record.Bytes(), nil
+}
+
+// DecodeBinaryLog converts the framed records of a msgpack or cbor log read
+// from r to JSON lines written to w, the entries the JSON encoding would have
+// written
+func DecodeBinaryLog(encoding string, r io.Reader, w io.Writer) error {
+	for i := 0; ; i++ {
+		record, err := ReadBinaryFrame(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		line, err := BinaryToJSON(encoding, record)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+}
+
+// BinaryToJSON converts one msgpack or cbor record, without its length
+// prefix, to JSON. Keys keep their order, bytes are written as base64 and
+// strings, floats and timestamps as zap's JSON encoder writes them.
+func BinaryToJSON(encoding string, record []byte) ([]byte, error) {
+	d := &binaryDecoder{data: record}
+	var dst []byte
+	var err error
+	switch encoding {
+	case common.EncodeMsgpack:
+		dst, err = d.msgpack(nil, 0)
+	case common.EncodeCBOR:
+		dst, err = d.cbor(nil, 0)
+	default:
+		return nil, fmt.Errorf("unknown binary encoding %q", encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.off != len(d.data) {
+		return nil, fmt.Errorf("%d trailing bytes after the record", len(d.data)-d.off)
+	}
+	return dst, nil
+}
+
+// binaryDecoder reads the values of a record
+type binaryDecoder struct {
+	data []byte
+	off  int
+}
+
+func (d *binaryDecoder) next(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return nil, errTruncatedRecord
+	}
+	b := d.data[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+func (d *binaryDecoder) byte() (byte, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// uint reads a big-endian unsigned integer of size bytes
+func (d *binaryDecoder) uint(size int) (uint64, error) {
+	b, err := d.next(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+// count checks that n elements of at least one byte can follow
+func (d *binaryDecoder) count(n uint64) (int, error) {
+	if n > uint64(len(d.data)-d.off) {
+		return 0, errTruncatedRecord
+	}
+	return int(n), nil
+}
+
+// key appends a map key, values that are not strings are quoted
+func (d *binaryDecoder) key(dst []byte, value func([]byte) ([]byte, error)) ([]byte, error) {
+	start := len(dst)
+	dst, err := value(dst)
+	if err != nil {
+		return nil, err
+	}
+	if dst[start] != '"' {
+		key := string(dst[start:])
+		dst = appendJSONString(dst[:start], key)
+	}
+	return append(dst, ':'), nil
+}
+
+func (d *binaryDecoder) msgpack(dst []byte, depth int) ([]byte, error) {
+	if depth > maxBinaryDepth {
+		return nil, errors.New("binary record nested too deeply")
+	}
+	b, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case b <= 0x7f:
+		return strconv.AppendUint(dst, uint64(b), 10), nil
+	case b <= 0x8f:
+		return d.msgpackMap(dst, uint64(b&0x0f), depth)
+	case b <= 0x9f:
+		return d.msgpackArray(dst, uint64(b&0x0f), depth)
+	case b <= 0xbf:
+		return d.appendString(dst, uint64(b&0x1f))
+	case b >= 0xe0:
+		return strconv.AppendInt(dst, int64(int8(b)), 10), nil
+	}
+
+	switch b {
+	case 0xc0:
+		return append(dst, "null"...), nil
+	case 0xc2:
+		return append(dst, "false"...), nil
+	case 0xc3:
+		return append(dst, "true"...), nil
+	case 0xca:
+		n, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return appendJSONFloat(dst, float64(math.Float32frombits(uint32(n))), 32), nil
+	case 0xcb:
+		n, err := d.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return appendJSONFloat(dst, math.Float64frombits(n), 64), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (b - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendUint(dst, n, 10), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend the size bytes integer
+		shift := 64 - 8*size
+		return strconv.AppendInt(dst, int64(n<<shift)>>shift, 10), nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb, 0xdc, 0xdd, 0xde, 0xdf:
+		return d.msgpackSized(dst, b, depth)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.msgpackExt(dst, uint64(1)<<(b-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.uint(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.msgpackExt(dst, n)
+	}
+	return nil, fmt.Errorf("invalid msgpack type 0x%02x", b)
+}
+
+// msgpackSized decodes the bin, str, array and map types with their length
+// in 1, 2 or 4 bytes
+func (d *binaryDecoder) msgpackSized(dst []byte, b byte, depth int) ([]byte, error) {
+	var size int
+	switch {
+	case b <= 0xc6:
+		size = 1 << (b - 0xc4)
+	case b <= 0xdb:
+		size = 1 << (b - 0xd9)
+	case b <= 0xdd:
+		size = 2 << (b - 0xdc)
+	default:
+		size = 2 << (b - 0xde)
+	}
+	n, err := d.uint(size)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case b <= 0xc6:
+		return d.appendBytes(dst, n)
+	case b <= 0xdb:
+		return d.appendString(dst, n)
+	case b <= 0xdd:
+		return d.msgpackArray(dst, n, depth)
+	}
+	return d.msgpackMap(dst, n, depth)
+}
+
+func (d *binaryDecoder) msgpackArray(dst []byte, n uint64, depth int) ([]byte, error) {
+	count, err := d.count(n)
+	if err != nil {
+		return nil, err
+	}
+	dst = append(dst, '[')
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		if dst, err = d.msgpack(dst, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, ']'), nil
+}
+
+func (d *binaryDecoder) msgpackMap(dst []byte, n uint64, depth int) ([]byte, error) {
+	count, err := d.count(n)
+	if err != nil {
+		return nil, err
+	}
+	dst = append(dst, '{')
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst, err = d.key(dst, func(dst []byte) ([]byte, error) { return d.msgpack(dst, depth+1) })
+		if err != nil {
+			return nil, err
+		}
+		if dst, err = d.msgpack(dst, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+// msgpackExt decodes an extension of n bytes. Timestamps, type -1, are
+// written as RFC 3339 strings, other extensions as their base64 data.
+func (d *binaryDecoder) msgpackExt(dst []byte, n uint64) ([]byte, error) {
+	typ, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ) != -1 {
+		return appendJSONString(dst, base64.StdEncoding.EncodeToString(data)), nil
+	}
+
+	var sec, nsec int64
+	switch len(data) {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		nsec, sec = int64(v>>34), int64(v&(1<<34-1))
+	case 12:
+		nsec = int64(binary.BigEndian.Uint32(data))
+		sec = int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		return nil, fmt.Errorf("invalid msgpack timestamp of %d bytes", len(data))
+	}
+	return appendJSONString(dst, time.Unix(sec, nsec).UTC().Format(time.RFC3339Nano)), nil
+}
+
+func (d *binaryDecoder) cbor(dst []byte, depth int) ([]byte, error) {
+	if depth > maxBinaryDepth {
+		return nil, errors.New("binary record nested too deeply")
+	}
+	b, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := b&0xe0, b&0x1f
+	if major == cborSimple {
+		return d.cborSimple(dst, info)
+	}
+
+	indefinite := info == 31
+	var n uint64
+	if !indefinite {
+		if n, err = d.cborArgument(info); err != nil {
+			return nil, err
+		}
+	} else if major == cborUint || major == cborNegInt || major == cborTag {
+		return nil, fmt.Errorf("invalid cbor item 0x%02x", b)
+	}
+
+	switch major {
+	case cborUint:
+		return strconv.AppendUint(dst, n, 10), nil
+	case cborNegInt:
+		// -1-n, which does not fit an int64 for the largest arguments
+		if n == math.MaxUint64 {
+			return append(dst, "-18446744073709551616"...), nil
+		}
+		return strconv.AppendUint(append(dst, '-'), n+1, 10), nil
+	case cborBytes, cborText:
+		if !indefinite {
+			if major == cborBytes {
+				return d.appendBytes(dst, n)
+			}
+			return d.appendString(dst, n)
+		}
+		chunks, err := d.cborChunks(major)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborBytes {
+			return appendJSONString(dst, base64.StdEncoding.EncodeToString(chunks)), nil
+		}
+		return appendJSONString(dst, string(chunks)), nil
+	case cborArray, cborMap:
+		return d.cborContainer(dst, major, n, indefinite, depth)
+	}
+	// Tags, such as epoch times or bignums, are written as their content
+	return d.cbor(dst, depth+1)
+}
+
+// cborArgument reads the argument of a head with additional info
+func (d *binaryDecoder) cborArgument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info <= 27:
+		return d.uint(1 << (info - 24))
+	}
+	return 0, fmt.Errorf("invalid cbor additional info %d", info)
+}
+
+// cborChunks reads the definite chunks of an indefinite byte or text string
+func (d *binaryDecoder) cborChunks(major byte) ([]byte, error) {
+	var chunks []byte
+	for {
+		b, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		if b == 0xff {
+			return chunks, nil
+		}
+		if b&0xe0 != major {
+			return nil, fmt.Errorf("invalid cbor chunk 0x%02x", b)
+		}
+		n, err := d.cborArgument(b & 0x1f)
+		if err != nil {
+			return nil, err
+		}
+		chunk, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk...)
+	}
+}
+
+func (d *binaryDecoder) cborContainer(dst []byte, major byte, n uint64, indefinite bool, depth int) ([]byte, error) {
+	start, end := byte('['), byte(']')
+	if major == cborMap {
+		start, end = '{', '}'
+	}
+	count := -1
+	if !indefinite {
+		var err error
+		if count, err = d.count(n); err != nil {
+			return nil, err
+		}
+	}
+
+	dst = append(dst, start)
+	for i := 0; ; i++ {
+		if indefinite {
+			if d.off < len(d.data) && d.data[d.off] == 0xff {
+				d.off++
+				break
+			}
+		} else if i == count {
+			break
+		}
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		var err error
+		if major == cborMap {
+			dst, err = d.key(dst, func(dst []byte) ([]byte, error) { return d.cbor(dst, depth+1) })
+			if err != nil {
+				return nil, err
+			}
+		}
+		if dst, err = d.cbor(dst, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, end), nil
+}
+
+// cborSimple decodes major type 7: simple values and floats
+func (d *binaryDecoder) cborSimple(dst []byte, info byte) ([]byte, error) {
+	switch info {
+	case 20:
+		return append(dst, "false"...), nil
+	case 21:
+		return append(dst, "true"...), nil
+	case 22, 23:
+		return append(dst, "null"...), nil
+	case 24:
+		v, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendUint(dst, uint64(v), 10), nil
+	case 25:
+		n, err := d.uint(2)
+		if err != nil {
+			return nil, err
+		}
+		return appendJSONFloat(dst, float64(halfToFloat32(uint16(n))), 32), nil
+	case 26:
+		n, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return appendJSONFloat(dst, float64(math.Float32frombits(uint32(n))), 32), nil
+	case 27:
+		n, err := d.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return appendJSONFloat(dst, math.Float64frombits(n), 64), nil
+	case 31:
+		return nil, errors.New("unexpected cbor break")
+	}
+	if info < 20 {
+		return strconv.AppendUint(dst, uint64(info), 10), nil
+	}
+	return nil, fmt.Errorf("invalid cbor simple value %d", info)
+}
+
+// halfToFloat32 converts an IEEE 754 half-precision float
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch exp {
+	case 0:
+		// Zero or subnormal, frac * 2^-24
+		f := float32(frac) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | frac<<13)
+}
+
+func (d *binaryDecoder) appendString(dst []byte, n uint64) ([]byte, error) {
+	s, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return appendJSONString(dst, string(s)), nil
+}
+
+func (d *binaryDecoder) appendBytes(dst []byte, n uint64) ([]byte, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return appendJSONString(dst, base64.StdEncoding.EncodeToString(b)), nil
+}
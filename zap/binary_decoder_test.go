@@ -0,0 +1,84 @@
+package zap
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+)
+
+func TestBinaryToJSON(t *testing.T) {
+	tests := []struct {
+		encoding string
+		record   string
+		want     string
+	}{
+		// Integers of every width
+		{common.EncodeMsgpack, "94cc80d1ff00ce80000000d3ffffffffffffffff", `[128,-256,2147483648,-1]`},
+		// Non-string keys are quoted
+		{common.EncodeMsgpack, "820102c3a178", `{"1":2,"true":"x"}`},
+		// Strings and bins with 1, 2 and 4 byte lengths
+		{common.EncodeMsgpack, "93d90161da000162c6000000010a", `["a","b","Cg=="]`},
+		// Timestamps of 32, 64 and 96 bits, other extensions as base64
+		{common.EncodeMsgpack, "94d6ff00000000d7ff0000000400000000c70cff000000010000000000000000d401ff",
+			`["1970-01-01T00:00:00Z","1970-01-01T00:00:00.000000001Z","1970-01-01T00:00:00.000000001Z","/w=="]`},
+		{common.EncodeMsgpack, "93ca7fc00000cbfff0000000000000ca3e800000", `["NaN","-Inf",0.25]`},
+		// Largest negative integer, indefinite strings and containers
+		{common.EncodeCBOR, "833bffffffffffffffff7f61616162ff5f4101ff",
+			`[-18446744073709551616,"ab","AQ=="]`},
+		{common.EncodeCBOR, "bf61619f01ffff", `{"a":[1]}`},
+		// Tags keep their content, half floats, simple and undefined values
+		{common.EncodeCBOR, "86c11a5e0be100f93e00f97c00f8fff0f7", `[1577836800,1.5,"+Inf",255,16,null]`},
+	}
+	for _, tt := range tests {
+		record, _ := hex.DecodeString(tt.record)
+		got, err := BinaryToJSON(tt.encoding, record)
+		if err != nil {
+			t.Errorf("%s %s: BinaryToJSON() error = %v", tt.encoding, tt.record, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s %s = %s, want %s", tt.encoding, tt.record, got, tt.want)
+		}
+	}
+}
+
+func TestBinaryToJSON_Invalid(t *testing.T) {
+	tests := []struct {
+		encoding string
+		record   string
+		err      string
+	}{
+		{"yaml", "80", "unknown binary encoding"},
+		{common.EncodeMsgpack, "82a161", "truncated"},
+		{common.EncodeMsgpack, "dfffffffff", "truncated"},
+		{common.EncodeMsgpack, "c1", "invalid msgpack type"},
+		{common.EncodeMsgpack, "8080", "trailing bytes"},
+		{common.EncodeMsgpack, "d6ff000000", "truncated"},
+		{common.EncodeCBOR, "ff", "unexpected cbor break"},
+		{common.EncodeCBOR, "1f", "invalid cbor item"},
+		{common.EncodeCBOR, "7f4100ff", "invalid cbor chunk"},
+		{common.EncodeCBOR, "9f01", "truncated"},
+		{common.EncodeCBOR, strings.Repeat("81", maxBinaryDepth+2) + "00", "nested too deeply"},
+	}
+	for _, tt := range tests {
+		record, _ := hex.DecodeString(tt.record)
+		if _, err := BinaryToJSON(tt.encoding, record); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s %s: BinaryToJSON() error = %v, want %q", tt.encoding, tt.record, err, tt.err)
+		}
+	}
+}
+
+func TestDecodeBinaryLog(t *testing.T) {
+	stream, _ := hex.DecodeString("00000004a1616101" + "00000001a0" + "00000005a16162")
+	var out bytes.Buffer
+	err := DecodeBinaryLog(common.EncodeCBOR, bytes.NewReader(stream), &out)
+	if err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Errorf("DecodeBinaryLog() error = %v, want a truncated record 2", err)
+	}
+	if out.String() != "{\"a\":1}\n{}\n" {
+		t.Errorf("DecodeBinaryLog() = %q, want the records before the error", out.String())
+	}
+}
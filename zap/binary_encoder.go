@@ -0,0 +1,490 @@
+package zap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// frameHeaderLen is the size of the big-endian uint32 length written before
+// every binary record
+const frameHeaderLen = 4
+
+// binaryFormats are the binary encodings by name
+var binaryFormats = map[string]binaryFormat{
+	common.EncodeMsgpack: msgpackFormat{},
+	common.EncodeCBOR:    cborFormat{},
+}
+
+// binaryLevel is a map being built: a header with the number of entries is
+// written when it is closed
+type binaryLevel struct {
+	// key is the key of a namespace in its parent
+	key   string
+	buf   []byte
+	count int
+}
+
+// binaryEncoder is a zapcore.Encoder writing entries as MessagePack or CBOR
+// maps with the same keys and values as the JSON encoding. Every entry is
+// framed with its length so records can be read back from files and
+// streams.
+type binaryEncoder struct {
+	cfg    *zapcore.EncoderConfig
+	format binaryFormat
+	// levels are the root map and the namespaces opened with OpenNamespace
+	levels []binaryLevel
+}
+
+// binaryEncoderName registers the binary encoder of encoding, nesting the
+// user fields as opts sets like the JSON encoding does, and returns its name
+func binaryEncoderName(encoding string, opts common.JSONOptions) (string, error) {
+	format, ok := binaryFormats[encoding]
+	if !ok {
+		return "", fmt.Errorf("unknown binary encoding %q", encoding)
+	}
+	newEncoder := func(cfg zapcore.EncoderConfig) zapcore.Encoder {
+		return newBinaryEncoder(cfg, format)
+	}
+	if opts.FieldsKey != "" {
+		return nestedEncoderName(encoding, opts, newEncoder, embedBinaryFields)
+	}
+	err := registerEncoder(encoding, func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return newEncoder(cfg), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return encoding, nil
+}
+
+// embedBinaryFields embeds the map a binaryEncoder built
+func embedBinaryFields(root, fields zapcore.Encoder, key string) error {
+	nested := fields.(*binaryEncoder)
+	if len(nested.levels) == 1 && nested.levels[0].count == 0 {
+		return nil
+	}
+	l := root.(*binaryEncoder).key(key)
+	l.buf = nested.appendTo(l.buf)
+	return nil
+}
+
+func newBinaryEncoder(cfg zapcore.EncoderConfig, format binaryFormat) *binaryEncoder {
+	return &binaryEncoder{cfg: &cfg, format: format, levels: []binaryLevel{{}}}
+}
+
+// key appends key to the current map and returns the level to append its
+// value to
+func (enc *binaryEncoder) key(key string) *binaryLevel {
+	l := &enc.levels[len(enc.levels)-1]
+	l.buf = enc.format.appendString(l.buf, key)
+	l.count++
+	return l
+}
+
+// closeNamespaces folds the open namespaces into the root map
+func (enc *binaryEncoder) closeNamespaces() {
+	for i := len(enc.levels) - 1; i > 0; i-- {
+		child, parent := enc.levels[i], &enc.levels[i-1]
+		parent.buf = enc.format.appendString(parent.buf, child.key)
+		parent.buf = enc.format.appendMapHeader(parent.buf, child.count)
+		parent.buf = append(parent.buf, child.buf...)
+		parent.count++
+	}
+	enc.levels = enc.levels[:1]
+}
+
+// appendTo closes the namespaces and appends the map to dst
+func (enc *binaryEncoder) appendTo(dst []byte) []byte {
+	enc.closeNamespaces()
+	dst = enc.format.appendMapHeader(dst, enc.levels[0].count)
+	return append(dst, enc.levels[0].buf...)
+}
+
+func (enc *binaryEncoder) Clone() zapcore.Encoder {
+	return enc.clone()
+}
+
+func (enc *binaryEncoder) clone() *binaryEncoder {
+	levels := make([]binaryLevel, len(enc.levels))
+	for i, l := range enc.levels {
+		levels[i] = binaryLevel{key: l.key, buf: append([]byte(nil), l.buf...), count: l.count}
+	}
+	return &binaryEncoder{cfg: enc.cfg, format: enc.format, levels: levels}
+}
+
+func (enc *binaryEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := newBinaryEncoder(*enc.cfg, enc.format)
+	cfg := enc.cfg
+
+	if cfg.LevelKey != "" && cfg.EncodeLevel != nil {
+		final.addEncoded(cfg.LevelKey, func(arr zapcore.PrimitiveArrayEncoder) {
+			cfg.EncodeLevel(entry.Level, arr)
+		}, func(arr *binaryArrayEncoder) { arr.AppendString(entry.Level.String()) })
+	}
+	if cfg.TimeKey != "" {
+		final.addEncoded(cfg.TimeKey, func(arr zapcore.PrimitiveArrayEncoder) {
+			if cfg.EncodeTime != nil {
+				cfg.EncodeTime(entry.Time, arr)
+			}
+		}, func(arr *binaryArrayEncoder) { arr.AppendInt64(entry.Time.UnixNano()) })
+	}
+	if entry.LoggerName != "" && cfg.NameKey != "" {
+		final.addEncoded(cfg.NameKey, func(arr zapcore.PrimitiveArrayEncoder) {
+			if cfg.EncodeName != nil {
+				cfg.EncodeName(entry.LoggerName, arr)
+			}
+		}, func(arr *binaryArrayEncoder) { arr.AppendString(entry.LoggerName) })
+	}
+	if entry.Caller.Defined {
+		if cfg.CallerKey != "" {
+			final.addEncoded(cfg.CallerKey, func(arr zapcore.PrimitiveArrayEncoder) {
+				if cfg.EncodeCaller != nil {
+					cfg.EncodeCaller(entry.Caller, arr)
+				}
+			}, func(arr *binaryArrayEncoder) { arr.AppendString(entry.Caller.String()) })
+		}
+		if cfg.FunctionKey != "" {
+			final.AddString(cfg.FunctionKey, entry.Caller.Function)
+		}
+	}
+	if cfg.MessageKey != "" {
+		final.AddString(cfg.MessageKey, entry.Message)
+	}
+
+	// The fields added with With, in their open namespaces
+	root := &final.levels[0]
+	root.buf = append(root.buf, enc.levels[0].buf...)
+	root.count += enc.levels[0].count
+	for _, l := range enc.levels[1:] {
+		final.levels = append(final.levels, binaryLevel{key: l.key, buf: append([]byte(nil), l.buf...), count: l.count})
+	}
+	for _, field := range fields {
+		field.AddTo(final)
+	}
+	final.closeNamespaces()
+	if entry.Stack != "" && cfg.StacktraceKey != "" {
+		final.AddString(cfg.StacktraceKey, entry.Stack)
+	}
+
+	buf := bufferPool.Get()
+	record := final.appendTo(make([]byte, frameHeaderLen, frameHeaderLen+len(final.levels[0].buf)+9))
+	binary.BigEndian.PutUint32(record, uint32(len(record)-frameHeaderLen))
+	buf.Write(record)
+	return buf, nil
+}
+
+// addEncoded adds the value appended by a zapcore encoder func such as
+// EncodeTime, using fallback when it appends nothing, as zap's JSON encoder
+// does
+func (enc *binaryEncoder) addEncoded(key string, encode func(zapcore.PrimitiveArrayEncoder), fallback func(*binaryArrayEncoder)) {
+	arr := &binaryArrayEncoder{cfg: enc.cfg, format: enc.format}
+	encode(arr)
+	if arr.count == 0 {
+		fallback(arr)
+	}
+	l := enc.key(key)
+	if arr.count == 1 {
+		l.buf = append(l.buf, arr.buf...)
+		return
+	}
+	l.buf = append(enc.format.appendArrayHeader(l.buf, arr.count), arr.buf...)
+}
+
+func (enc *binaryEncoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	arr := &binaryArrayEncoder{cfg: enc.cfg, format: enc.format}
+	err := marshaler.MarshalLogArray(arr)
+	l := enc.key(key)
+	l.buf = append(enc.format.appendArrayHeader(l.buf, arr.count), arr.buf...)
+	return err
+}
+
+func (enc *binaryEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	obj := newBinaryEncoder(*enc.cfg, enc.format)
+	err := marshaler.MarshalLogObject(obj)
+	l := enc.key(key)
+	l.buf = obj.appendTo(l.buf)
+	return err
+}
+
+func (enc *binaryEncoder) AddBinary(key string, val []byte) {
+	l := enc.key(key)
+	l.buf = enc.format.appendBytes(l.buf, val)
+}
+
+func (enc *binaryEncoder) AddByteString(key string, val []byte) {
+	enc.AddString(key, string(val))
+}
+
+func (enc *binaryEncoder) AddBool(key string, val bool) {
+	l := enc.key(key)
+	l.buf = enc.format.appendBool(l.buf, val)
+}
+
+func (enc *binaryEncoder) AddComplex128(key string, val complex128) {
+	enc.AddString(key, formatComplex(val, 64))
+}
+
+func (enc *binaryEncoder) AddComplex64(key string, val complex64) {
+	enc.AddString(key, formatComplex(complex128(val), 32))
+}
+
+func (enc *binaryEncoder) AddDuration(key string, val time.Duration) {
+	enc.addEncoded(key, func(arr zapcore.PrimitiveArrayEncoder) {
+		if enc.cfg.EncodeDuration != nil {
+			enc.cfg.EncodeDuration(val, arr)
+		}
+	}, func(arr *binaryArrayEncoder) { arr.AppendInt64(int64(val)) })
+}
+
+func (enc *binaryEncoder) AddFloat64(key string, val float64) {
+	l := enc.key(key)
+	l.buf = enc.format.appendFloat64(l.buf, val)
+}
+
+func (enc *binaryEncoder) AddFloat32(key string, val float32) {
+	l := enc.key(key)
+	l.buf = enc.format.appendFloat32(l.buf, val)
+}
+
+func (enc *binaryEncoder) AddInt(key string, val int)     { enc.AddInt64(key, int64(val)) }
+func (enc *binaryEncoder) AddInt32(key string, val int32) { enc.AddInt64(key, int64(val)) }
+func (enc *binaryEncoder) AddInt16(key string, val int16) { enc.AddInt64(key, int64(val)) }
+func (enc *binaryEncoder) AddInt8(key string, val int8)   { enc.AddInt64(key, int64(val)) }
+
+func (enc *binaryEncoder) AddInt64(key string, val int64) {
+	l := enc.key(key)
+	l.buf = enc.format.appendInt(l.buf, val)
+}
+
+func (enc *binaryEncoder) AddString(key, val string) {
+	l := enc.key(key)
+	l.buf = enc.format.appendString(l.buf, val)
+}
+
+func (enc *binaryEncoder) AddTime(key string, val time.Time) {
+	enc.addEncoded(key, func(arr zapcore.PrimitiveArrayEncoder) {
+		if enc.cfg.EncodeTime != nil {
+			enc.cfg.EncodeTime(val, arr)
+		}
+	}, func(arr *binaryArrayEncoder) { arr.AppendInt64(val.UnixNano()) })
+}
+
+func (enc *binaryEncoder) AddUint(key string, val uint)       { enc.AddUint64(key, uint64(val)) }
+func (enc *binaryEncoder) AddUint32(key string, val uint32)   { enc.AddUint64(key, uint64(val)) }
+func (enc *binaryEncoder) AddUint16(key string, val uint16)   { enc.AddUint64(key, uint64(val)) }
+func (enc *binaryEncoder) AddUint8(key string, val uint8)     { enc.AddUint64(key, uint64(val)) }
+func (enc *binaryEncoder) AddUintptr(key string, val uintptr) { enc.AddUint64(key, uint64(val)) }
+
+func (enc *binaryEncoder) AddUint64(key string, val uint64) {
+	l := enc.key(key)
+	l.buf = enc.format.appendUint(l.buf, val)
+}
+
+// AddReflected encodes val as encoding/json does, so values keep their
+// MarshalJSON methods, struct tags and key order
+func (enc *binaryEncoder) AddReflected(key string, val interface{}) error {
+	value, err := reflectedValue(enc.format, val)
+	if err != nil {
+		return err
+	}
+	l := enc.key(key)
+	l.buf = append(l.buf, value...)
+	return nil
+}
+
+func (enc *binaryEncoder) OpenNamespace(key string) {
+	enc.levels = append(enc.levels, binaryLevel{key: key})
+}
+
+// binaryArrayEncoder is the zapcore.ArrayEncoder of binaryEncoder
+type binaryArrayEncoder struct {
+	cfg    *zapcore.EncoderConfig
+	format binaryFormat
+	buf    []byte
+	count  int
+}
+
+func (arr *binaryArrayEncoder) AppendArray(marshaler zapcore.ArrayMarshaler) error {
+	inner := &binaryArrayEncoder{cfg: arr.cfg, format: arr.format}
+	err := marshaler.MarshalLogArray(inner)
+	arr.buf = append(arr.format.appendArrayHeader(arr.buf, inner.count), inner.buf...)
+	arr.count++
+	return err
+}
+
+func (arr *binaryArrayEncoder) AppendObject(marshaler zapcore.ObjectMarshaler) error {
+	obj := newBinaryEncoder(*arr.cfg, arr.format)
+	err := marshaler.MarshalLogObject(obj)
+	arr.buf = obj.appendTo(arr.buf)
+	arr.count++
+	return err
+}
+
+func (arr *binaryArrayEncoder) AppendReflected(val interface{}) error {
+	value, err := reflectedValue(arr.format, val)
+	if err != nil {
+		return err
+	}
+	arr.buf = append(arr.buf, value...)
+	arr.count++
+	return nil
+}
+
+func (arr *binaryArrayEncoder) AppendBool(val bool) {
+	arr.buf = arr.format.appendBool(arr.buf, val)
+	arr.count++
+}
+
+func (arr *binaryArrayEncoder) AppendByteString(val []byte) {
+	arr.AppendString(string(val))
+}
+
+func (arr *binaryArrayEncoder) AppendComplex128(val complex128) {
+	arr.AppendString(formatComplex(val, 64))
+}
+
+func (arr *binaryArrayEncoder) AppendComplex64(val complex64) {
+	arr.AppendString(formatComplex(complex128(val), 32))
+}
+
+func (arr *binaryArrayEncoder) AppendFloat64(val float64) {
+	arr.buf = arr.format.appendFloat64(arr.buf, val)
+	arr.count++
+}
+
+func (arr *binaryArrayEncoder) AppendFloat32(val float32) {
+	arr.buf = arr.format.appendFloat32(arr.buf, val)
+	arr.count++
+}
+
+func (arr *binaryArrayEncoder) AppendInt(val int)     { arr.AppendInt64(int64(val)) }
+func (arr *binaryArrayEncoder) AppendInt32(val int32) { arr.AppendInt64(int64(val)) }
+func (arr *binaryArrayEncoder) AppendInt16(val int16) { arr.AppendInt64(int64(val)) }
+func (arr *binaryArrayEncoder) AppendInt8(val int8)   { arr.AppendInt64(int64(val)) }
+
+func (arr *binaryArrayEncoder) AppendInt64(val int64) {
+	arr.buf = arr.format.appendInt(arr.buf, val)
+	arr.count++
+}
+
+func (arr *binaryArrayEncoder) AppendString(val string) {
+	arr.buf = arr.format.appendString(arr.buf, val)
+	arr.count++
+}
+
+func (arr *binaryArrayEncoder) AppendUint(val uint)       { arr.AppendUint64(uint64(val)) }
+func (arr *binaryArrayEncoder) AppendUint32(val uint32)   { arr.AppendUint64(uint64(val)) }
+func (arr *binaryArrayEncoder) AppendUint16(val uint16)   { arr.AppendUint64(uint64(val)) }
+func (arr *binaryArrayEncoder) AppendUint8(val uint8)     { arr.AppendUint64(uint64(val)) }
+func (arr *binaryArrayEncoder) AppendUintptr(val uintptr) { arr.AppendUint64(uint64(val)) }
+
+func (arr *binaryArrayEncoder) AppendUint64(val uint64) {
+	arr.buf = arr.format.appendUint(arr.buf, val)
+	arr.count++
+}
+
+func (arr *binaryArrayEncoder) AppendDuration(val time.Duration) {
+	before := arr.count
+	if arr.cfg.EncodeDuration != nil {
+		arr.cfg.EncodeDuration(val, arr)
+	}
+	if arr.count == before {
+		arr.AppendInt64(int64(val))
+	}
+}
+
+func (arr *binaryArrayEncoder) AppendTime(val time.Time) {
+	before := arr.count
+	if arr.cfg.EncodeTime != nil {
+		arr.cfg.EncodeTime(val, arr)
+	}
+	if arr.count == before {
+		arr.AppendInt64(val.UnixNano())
+	}
+}
+
+// formatComplex formats val like zap's JSON encoder, "1+2i"
+func formatComplex(val complex128, bitSize int) string {
+	r, i := real(val), imag(val)
+	s := strconv.FormatFloat(r, 'f', -1, bitSize)
+	if i >= 0 {
+		s += "+"
+	}
+	return s + strconv.FormatFloat(i, 'f', -1, bitSize) + "i"
+}
+
+// reflectedValue encodes val with encoding/json and converts the JSON to
+// format, keeping the key order
+func reflectedValue(format binaryFormat, val interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	jsonEnc := json.NewEncoder(&buf)
+	jsonEnc.SetEscapeHTML(false)
+	if err := jsonEnc.Encode(val); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(&buf)
+	dec.UseNumber()
+	return appendJSONValue(format, nil, dec)
+}
+
+func appendJSONValue(format binaryFormat, dst []byte, dec *json.Decoder) ([]byte, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		var body []byte
+		n := 0
+		for dec.More() {
+			if v == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				body = format.appendString(body, key.(string))
+			}
+			if body, err = appendJSONValue(format, body, dec); err != nil {
+				return nil, err
+			}
+			n++
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		if v == '{' {
+			dst = format.appendMapHeader(dst, n)
+		} else {
+			dst = format.appendArrayHeader(dst, n)
+		}
+		return append(dst, body...), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return format.appendInt(dst, i), nil
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return format.appendUint(dst, u), nil
+		}
+		f, err := v.Float64()
+		if err != nil && !math.IsInf(f, 0) {
+			return nil, err
+		}
+		return format.appendFloat64(dst, f), nil
+	case string:
+		return format.appendString(dst, v), nil
+	case bool:
+		return format.appendBool(dst, v), nil
+	case nil:
+		return format.appendNil(dst), nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", tok)
+}
@@ -0,0 +1,266 @@
+package zap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var binaryEncodings = []string{common.EncodeMsgpack, common.EncodeCBOR}
+
+// binaryEntries are the golden entries plus the cases only the JSON
+// encoding shares with the binary ones
+var binaryEntries = append(goldenEntries[:len(goldenEntries):len(goldenEntries)], []struct {
+	name   string
+	with   []zapcore.Field
+	fields []zapcore.Field
+}{
+	{name: "escaping", fields: []zapcore.Field{
+		zap.String("s", "quote \" back \\ nl\n tab\t ctrl\x01 <html> é 日本"), zap.String("invalid", "a\xffb"),
+	}},
+	{name: "with namespace", with: []zapcore.Field{zap.String("svc", "api"), zap.Namespace("req")},
+		fields: []zapcore.Field{zap.Int("id", 7), zap.Namespace("inner"), zap.Bool("ok", true)}},
+	{name: "reflected", fields: []zapcore.Field{
+		zap.Any("struct", struct {
+			B string  `json:"b"`
+			A []int   `json:"a"`
+			F float64 `json:"f"`
+			N *int    `json:"n"`
+		}{"x", []int{1, -2}, 1.5, nil}),
+		zap.Any("big", uint64(1<<63)), zap.Reflect("nested", map[string]interface{}{"m": map[string]bool{"t": true}}),
+	}},
+}...)
+
+// encodeBinary encodes entry with the binary encoder of encoding and returns
+// the framed record and what zap's JSON encoder writes for it
+func encodeBinary(t *testing.T, encoding string, entry zapcore.Entry, with, fields []zapcore.Field) ([]byte, string) {
+	t.Helper()
+	cfg := newEncoderConfig()
+	encoders := []zapcore.Encoder{newBinaryEncoder(cfg, binaryFormats[encoding]), zapcore.NewJSONEncoder(cfg)}
+	var out [2][]byte
+	for i, enc := range encoders {
+		enc = enc.Clone()
+		for _, field := range with {
+			field.AddTo(enc)
+		}
+		buf, err := enc.EncodeEntry(entry, fields)
+		if err != nil {
+			t.Fatalf("EncodeEntry(%s) error = %v", entry.Message, err)
+		}
+		out[i] = append([]byte(nil), buf.Bytes()...)
+		buf.Free()
+	}
+	return out[0], strings.TrimSuffix(string(out[1]), "\n")
+}
+
+func TestBinaryEncoder_MatchesJSON(t *testing.T) {
+	for _, encoding := range binaryEncodings {
+		for _, tt := range binaryEntries {
+			entry := zapcore.Entry{
+				Level:      zapcore.WarnLevel,
+				Time:       time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC),
+				LoggerName: "-.api",
+				Message:    tt.name,
+				Caller:     zapcore.NewEntryCaller(0, "/src/app/main.go", 42, true),
+				Stack:      "main.main\n\t/src/app/main.go:42",
+			}
+			framed, want := encodeBinary(t, encoding, entry, tt.with, tt.fields)
+
+			if n := binary.BigEndian.Uint32(framed); int(n) != len(framed)-frameHeaderLen {
+				t.Errorf("%s %s: frame length = %d, want %d", encoding, tt.name, n, len(framed)-frameHeaderLen)
+			}
+			got, err := BinaryToJSON(encoding, framed[frameHeaderLen:])
+			if err != nil {
+				t.Fatalf("%s %s: BinaryToJSON() error = %v", encoding, tt.name, err)
+			}
+			if string(got) != want {
+				t.Errorf("%s %s:\ngot  %s\nwant %s", encoding, tt.name, got, want)
+			}
+		}
+	}
+}
+
+func TestBinaryEncoder_Vectors(t *testing.T) {
+	tests := []struct {
+		encoding string
+		fields   []zapcore.Field
+		want     string
+	}{
+		{common.EncodeMsgpack, []zapcore.Field{zap.Int("a", 1), zap.Int("b", -33), zap.Bool("c", true)},
+			"83a16101a162d0dfa163c3"},
+		{common.EncodeMsgpack, []zapcore.Field{zap.Uint64("u", 1<<32), zap.Float64("f", 1), zap.Binary("b", []byte{0xff})},
+			"83a175cf0000000100000000a166cb3ff0000000000000a162c401ff"},
+		{common.EncodeMsgpack, []zapcore.Field{zap.Ints("l", []int{1, 2}), zap.Any("n", nil)},
+			"82a16c920102a16ec0"},
+		{common.EncodeCBOR, []zapcore.Field{zap.Int("a", 1), zap.Int("b", -33), zap.Bool("c", true)},
+			"a3616101616238206163f5"},
+		{common.EncodeCBOR, []zapcore.Field{zap.Uint64("u", 1<<32), zap.Float32("f", 1), zap.Binary("b", []byte{0xff})},
+			"a361751b00000001000000006166fa3f800000616241ff"},
+		{common.EncodeCBOR, []zapcore.Field{zap.Ints("l", []int{1, 2}), zap.Any("n", nil)},
+			"a2616c820102616ef6"},
+	}
+	for _, tt := range tests {
+		// Without keys in the config only the fields are encoded
+		buf, err := newBinaryEncoder(zapcore.EncoderConfig{}, binaryFormats[tt.encoding]).EncodeEntry(zapcore.Entry{}, tt.fields)
+		if err != nil {
+			t.Fatalf("EncodeEntry() error = %v", err)
+		}
+		if got := hex.EncodeToString(buf.Bytes()[frameHeaderLen:]); got != tt.want {
+			t.Errorf("%s %v = %s, want %s", tt.encoding, tt.fields, got, tt.want)
+		}
+		buf.Free()
+	}
+}
+
+// decodeJSONEntry decodes a JSON line keeping numbers as json.Number
+func decodeJSONEntry(t *testing.T, line string) map[string]interface{} {
+	t.Helper()
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	var entry map[string]interface{}
+	if err := dec.Decode(&entry); err != nil {
+		t.Fatalf("invalid JSON %s: %v", line, err)
+	}
+	return entry
+}
+
+func TestBinaryEncoding_Logger(t *testing.T) {
+	for _, encoding := range binaryEncodings {
+		logger, logFile := newFileLogger(t, func(o *common.Options) { o.Encoding = encoding })
+		before := time.Now()
+		logger.Infow("first", "k", "v")
+		logger.With(common.KeyTraceID, "t-1").Errorw("second", "n", 2)
+
+		var out bytes.Buffer
+		if err := DecodeBinaryLog(encoding, strings.NewReader(readFile(t, logFile)), &out); err != nil {
+			t.Fatalf("DecodeBinaryLog(%s) error = %v", encoding, err)
+		}
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("%s: got %d lines, want 2: %q", encoding, len(lines), out.String())
+		}
+		if !strings.Contains(lines[0], `"level":"[info]"`) || !strings.Contains(lines[0], `"msg":"first","k":"v"}`) {
+			t.Errorf("%s: first entry = %s", encoding, lines[0])
+		}
+		if !strings.Contains(lines[1], `"msg":"second","trace_id":"t-1","n":2`) {
+			t.Errorf("%s: second entry = %s", encoding, lines[1])
+		}
+
+		// Timestamps are epoch nanoseconds unless a format is set
+		ts, err := decodeJSONEntry(t, lines[0])["ts"].(json.Number).Int64()
+		if err != nil || ts < before.UnixNano() {
+			t.Errorf("%s: ts = %s, want epoch nanoseconds", encoding, lines[0])
+		}
+	}
+}
+
+func TestBinaryEncoding_JSONOptions(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithCBOREncoding(),
+		common.WithJSONKeys(common.JSONKeys{Message: "message", Caller: common.OmitKey}),
+		common.WithJSONLevelCase(common.LevelUpper), common.WithTimestampFormat(common.TimestampRFC3339))
+	logger.Warn("renamed")
+
+	var out bytes.Buffer
+	if err := DecodeBinaryLog(common.EncodeCBOR, strings.NewReader(readFile(t, logFile)), &out); err != nil {
+		t.Fatalf("DecodeBinaryLog() error = %v", err)
+	}
+	entry := decodeJSONEntry(t, out.String())
+	if entry["message"] != "renamed" || entry["level"] != "WARN" || entry["caller"] != nil {
+		t.Errorf("JSON key options should apply, got %v", entry)
+	}
+	if _, err := time.Parse(time.RFC3339, entry["ts"].(string)); err != nil {
+		t.Errorf("ts = %v, want RFC 3339", entry["ts"])
+	}
+}
+
+func TestBinaryEncoder_NestedFieldsMatchJSON(t *testing.T) {
+	entry := zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Unix(1, 0).UTC(), Message: "nested"}
+	with := []zapcore.Field{zap.String(common.KeyTraceID, "t-1"), zap.String("svc", "api")}
+	fields := []zapcore.Field{zap.Int("attempt", 2), zap.Namespace("req"), zap.String("request_id", "r-1"), zap.Bool("ok", true)}
+	for _, encoding := range binaryEncodings {
+		format := binaryFormats[encoding]
+		newEncoder := func(cfg zapcore.EncoderConfig) zapcore.Encoder { return newBinaryEncoder(cfg, format) }
+		encoders := []zapcore.Encoder{
+			newNestedEncoder(newEncoderConfig(), newEncoder, embedBinaryFields, "fields", []string{common.KeyTraceID, "request_id"}),
+			newNestedEncoder(newEncoderConfig(), zapcore.NewJSONEncoder, embedJSONFields, "fields", []string{common.KeyTraceID, "request_id"}),
+		}
+		var out [2]string
+		for i, enc := range encoders {
+			enc = enc.Clone()
+			for _, field := range with {
+				field.AddTo(enc)
+			}
+			buf, err := enc.EncodeEntry(entry, fields)
+			if err != nil {
+				t.Fatalf("%s: EncodeEntry() error = %v", encoding, err)
+			}
+			out[i] = buf.String()
+			buf.Free()
+		}
+		got, err := BinaryToJSON(encoding, []byte(out[0])[frameHeaderLen:])
+		if err != nil {
+			t.Fatalf("%s: BinaryToJSON() error = %v", encoding, err)
+		}
+		if want := strings.TrimSuffix(out[1], "\n"); string(got) != want {
+			t.Errorf("%s:\ngot  %s\nwant %s", encoding, got, want)
+		}
+		if !strings.Contains(string(got), `"trace_id":"t-1","request_id":"r-1","fields":{"svc":"api","attempt":2,"req":{"ok":true}}`) {
+			t.Errorf("%s: user fields should be nested, got %s", encoding, got)
+		}
+	}
+}
+
+func TestBinaryEncoding_FieldsKey(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithMsgpackEncoding(),
+		common.WithJSONFieldsKey("data"))
+	logger.With(common.KeyTraceID, "t-1").Infow("nested", "k", "v")
+	logger.Info("empty")
+
+	var out bytes.Buffer
+	if err := DecodeBinaryLog(common.EncodeMsgpack, strings.NewReader(readFile(t, logFile)), &out); err != nil {
+		t.Fatalf("DecodeBinaryLog() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), out.String())
+	}
+	entry := decodeJSONEntry(t, lines[0])
+	if data, _ := entry["data"].(map[string]interface{}); data["k"] != "v" || entry[common.KeyTraceID] != "t-1" || entry["k"] != nil {
+		t.Errorf("user fields should be nested under data, got %s", lines[0])
+	}
+	if _, ok := decodeJSONEntry(t, lines[1])["data"]; ok {
+		t.Errorf("entries without user fields should have no data key, got %s", lines[1])
+	}
+}
+
+func TestReadBinaryFrame(t *testing.T) {
+	var stream bytes.Buffer
+	for _, record := range []string{"\x80", "\x81\xa1k\x01"} {
+		stream.Write(binary.BigEndian.AppendUint32(nil, uint32(len(record))))
+		stream.WriteString(record)
+	}
+	for _, want := range []string{"\x80", "\x81\xa1k\x01"} {
+		got, err := ReadBinaryFrame(&stream)
+		if err != nil || string(got) != want {
+			t.Fatalf("ReadBinaryFrame() = %q, %v, want %q", got, err, want)
+		}
+	}
+	if _, err := ReadBinaryFrame(&stream); err != io.EOF {
+		t.Errorf("ReadBinaryFrame() at the end error = %v, want io.EOF", err)
+	}
+
+	for _, truncated := range []string{"\x00\x00", "\x00\x00\x00\x05\x80"} {
+		if _, err := ReadBinaryFrame(strings.NewReader(truncated)); err != errTruncatedRecord {
+			t.Errorf("ReadBinaryFrame(%q) error = %v, want %v", truncated, err, errTruncatedRecord)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package zap
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// binaryFormat appends the values of a binary encoding. Maps and arrays are
+// written as a header with their length followed by their elements.
+type binaryFormat interface {
+	appendNil(dst []byte) []byte
+	appendBool(dst []byte, v bool) []byte
+	appendInt(dst []byte, v int64) []byte
+	appendUint(dst []byte, v uint64) []byte
+	appendFloat32(dst []byte, v float32) []byte
+	appendFloat64(dst []byte, v float64) []byte
+	appendString(dst []byte, v string) []byte
+	appendBytes(dst []byte, v []byte) []byte
+	appendArrayHeader(dst []byte, n int) []byte
+	appendMapHeader(dst []byte, n int) []byte
+}
+
+// msgpackFormat is MessagePack, see https://github.com/msgpack/msgpack/blob/master/spec.md
+type msgpackFormat struct{}
+
+func (msgpackFormat) appendNil(dst []byte) []byte {
+	return append(dst, 0xc0)
+}
+
+func (msgpackFormat) appendBool(dst []byte, v bool) []byte {
+	if v {
+		return append(dst, 0xc3)
+	}
+	return append(dst, 0xc2)
+}
+
+func (f msgpackFormat) appendInt(dst []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return f.appendUint(dst, uint64(v))
+	case v >= -32:
+		return append(dst, byte(v))
+	case v >= math.MinInt8:
+		return append(dst, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(dst, 0xd1), uint16(v))
+	case v >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(dst, 0xd2), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(dst, 0xd3), uint64(v))
+}
+
+func (msgpackFormat) appendUint(dst []byte, v uint64) []byte {
+	switch {
+	case v < 0x80:
+		return append(dst, byte(v))
+	case v <= math.MaxUint8:
+		return append(dst, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, 0xcd), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(dst, 0xce), uint32(v))
+	}
+	return binary.BigEndian.AppendUint64(append(dst, 0xcf), v)
+}
+
+func (msgpackFormat) appendFloat32(dst []byte, v float32) []byte {
+	return binary.BigEndian.AppendUint32(append(dst, 0xca), math.Float32bits(v))
+}
+
+func (msgpackFormat) appendFloat64(dst []byte, v float64) []byte {
+	return binary.BigEndian.AppendUint64(append(dst, 0xcb), math.Float64bits(v))
+}
+
+func (msgpackFormat) appendString(dst []byte, v string) []byte {
+	n := len(v)
+	switch {
+	case n < 32:
+		dst = append(dst, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		dst = append(dst, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		dst = binary.BigEndian.AppendUint16(append(dst, 0xda), uint16(n))
+	default:
+		dst = binary.BigEndian.AppendUint32(append(dst, 0xdb), uint32(n))
+	}
+	return append(dst, v...)
+}
+
+func (msgpackFormat) appendBytes(dst []byte, v []byte) []byte {
+	n := len(v)
+	switch {
+	case n <= math.MaxUint8:
+		dst = append(dst, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		dst = binary.BigEndian.AppendUint16(append(dst, 0xc5), uint16(n))
+	default:
+		dst = binary.BigEndian.AppendUint32(append(dst, 0xc6), uint32(n))
+	}
+	return append(dst, v...)
+}
+
+func (msgpackFormat) appendArrayHeader(dst []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(dst, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, 0xdc), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(dst, 0xdd), uint32(n))
+}
+
+func (msgpackFormat) appendMapHeader(dst []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(dst, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, 0xde), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(dst, 0xdf), uint32(n))
+}
+
+// CBOR major types, see RFC 8949
+const (
+	cborUint   = 0 << 5
+	cborNegInt = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+	cborSimple = 7 << 5
+)
+
+// cborFormat is CBOR, see https://www.rfc-editor.org/rfc/rfc8949
+type cborFormat struct{}
+
+// appendCBORHead appends the head of a data item of major type with the
+// argument n in its shortest form
+func appendCBORHead(dst []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(dst, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(dst, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(dst, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(dst, major|27), n)
+}
+
+func (cborFormat) appendNil(dst []byte) []byte {
+	return append(dst, cborSimple|22)
+}
+
+func (cborFormat) appendBool(dst []byte, v bool) []byte {
+	if v {
+		return append(dst, cborSimple|21)
+	}
+	return append(dst, cborSimple|20)
+}
+
+func (cborFormat) appendInt(dst []byte, v int64) []byte {
+	if v >= 0 {
+		return appendCBORHead(dst, cborUint, uint64(v))
+	}
+	return appendCBORHead(dst, cborNegInt, uint64(-1-v))
+}
+
+func (cborFormat) appendUint(dst []byte, v uint64) []byte {
+	return appendCBORHead(dst, cborUint, v)
+}
+
+func (cborFormat) appendFloat32(dst []byte, v float32) []byte {
+	return binary.BigEndian.AppendUint32(append(dst, cborSimple|26), math.Float32bits(v))
+}
+
+func (cborFormat) appendFloat64(dst []byte, v float64) []byte {
+	return binary.BigEndian.AppendUint64(append(dst, cborSimple|27), math.Float64bits(v))
+}
+
+func (cborFormat) appendString(dst []byte, v string) []byte {
+	return append(appendCBORHead(dst, cborText, uint64(len(v))), v...)
+}
+
+func (cborFormat) appendBytes(dst []byte, v []byte) []byte {
+	return append(appendCBORHead(dst, cborBytes, uint64(len(v))), v...)
+}
+
+func (cborFormat) appendArrayHeader(dst []byte, n int) []byte {
+	return appendCBORHead(dst, cborArray, uint64(n))
+}
+
+func (cborFormat) appendMapHeader(dst []byte, n int) []byte {
+	return appendCBORHead(dst, cborMap, uint64(n))
+}
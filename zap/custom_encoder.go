@@ -1,68 +1,314 @@
 package zap
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gw123/glog/common"
+	"go.uber.org/zap"
 	"go.uber.org/zap/buffer"
 	"go.uber.org/zap/zapcore"
 )
 
-// customConsoleEncoder is a custom encoder that places trace_id in a fixed position
+// customConsoleEncoder is a custom encoder that places trace_id and the
+// registered top fields in fixed positions, or renders entries with a layout
+// template
 type customConsoleEncoder struct {
 	zapcore.Encoder
-	cfg     zapcore.EncoderConfig
-	traceID string
+	cfg    zapcore.EncoderConfig
+	opts   consoleOptions
+	layout *consoleLayout
+	// slots are the values of the fields with a fixed position added with
+	// With
+	slots map[string]string
+	// blocks are the multi-line values added with With in pretty mode
+	blocks []prettyBlock
 }
 
-func newCustomConsoleEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
-	return &customConsoleEncoder{
-		Encoder: zapcore.NewConsoleEncoder(cfg),
+// consoleOptions are the console encoder settings taken from common.Options
+type consoleOptions struct {
+	// multilineStack renders stacks as an indented block below the entry
+	// instead of escaped into the field object
+	multilineStack bool
+	// layout is the line template, the built-in layout when empty
+	layout string
+	// timeFormat is a named format or a layout of common.WithTimestampFormat,
+	// DateTimeFormat when empty
+	timeFormat string
+	// timezone converts entry times, local time when nil
+	timezone *time.Location
+	// pretty uses the developer-friendly layout
+	pretty bool
+	// color adds ANSI colors
+	color bool
+}
+
+var registeredEncoders sync.Map
+
+// bufferPool holds the buffers of the encoders, entries included
+var bufferPool = buffer.NewPool()
+
+// consoleEncoderName returns the name of a registered console encoder using
+// opts, registering it on first use. zap builds encoders by name from an
+// EncoderConfig only, so each distinct set of options gets its own name.
+func consoleEncoderName(opts consoleOptions) (string, error) {
+	if opts == (consoleOptions{}) {
+		return "custom-console", nil
+	}
+	if opts.layout != "" {
+		if _, err := parseConsoleLayout(opts.layout); err != nil {
+			return "", err
+		}
+	}
+	name := fmt.Sprintf("custom-console-%+v", opts)
+	err := registerEncoder(name, func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return newCustomConsoleEncoder(cfg, opts), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// registerEncoder registers an encoder constructor under name unless an
+// encoder was already registered under that name by this package
+func registerEncoder(name string, constructor func(zapcore.EncoderConfig) (zapcore.Encoder, error)) error {
+	if _, loaded := registeredEncoders.LoadOrStore(name, struct{}{}); loaded {
+		return nil
+	}
+	if err := zap.RegisterEncoder(name, constructor); err != nil {
+		registeredEncoders.Delete(name)
+		return err
+	}
+	return nil
+}
+
+// newCustomConsoleEncoder returns the console encoder. Fields, including
+// those added with With, are collected by an embedded JSON encoder and
+// rendered as the trailing object, except those with a fixed position. An
+// invalid layout, rejected earlier by consoleEncoderName, falls back to the
+// built-in layout.
+func newCustomConsoleEncoder(cfg zapcore.EncoderConfig, opts consoleOptions) zapcore.Encoder {
+	enc := &customConsoleEncoder{
+		Encoder: zapcore.NewJSONEncoder(fieldsEncoderConfig(cfg)),
 		cfg:     cfg,
-		traceID: "",
+		opts:    opts,
+	}
+	if opts.layout != "" {
+		enc.layout, _ = parseConsoleLayout(opts.layout)
+	}
+	return enc
+}
+
+// fieldsEncoderConfig returns the config of the JSON encoder rendering the
+// trailing field object: no entry keys, and durations and times of fields in
+// readable forms
+func fieldsEncoderConfig(cfg zapcore.EncoderConfig) zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
+		SkipLineEnding: true,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeLevel:    cfg.EncodeLevel,
+		EncodeCaller:   cfg.EncodeCaller,
+		EncodeName:     cfg.EncodeName,
 	}
 }
 
 func (enc *customConsoleEncoder) Clone() zapcore.Encoder {
+	slots := make(map[string]string, len(enc.slots))
+	for k, v := range enc.slots {
+		slots[k] = v
+	}
 	return &customConsoleEncoder{
 		Encoder: enc.Encoder.Clone(),
 		cfg:     enc.cfg,
-		traceID: enc.traceID,
+		opts:    enc.opts,
+		layout:  enc.layout,
+		slots:   slots,
+		blocks:  enc.blocks[:len(enc.blocks):len(enc.blocks)],
+	}
+}
+
+// isSlot reports whether the field key has a fixed position: trace_id and
+// the registered top fields in the built-in layout, the placeholders of a
+// layout template otherwise
+func (enc *customConsoleEncoder) isSlot(key string) bool {
+	if enc.layout != nil {
+		return enc.layout.slots[key]
 	}
+	return key == common.KeyTraceID || common.IsTopField(key)
+}
+
+// captureTopField stores the value of a field with a fixed position
+func (enc *customConsoleEncoder) captureTopField(key, val string) bool {
+	if !enc.isSlot(key) {
+		return false
+	}
+	if enc.slots == nil {
+		enc.slots = map[string]string{}
+	}
+	enc.slots[key] = val
+	return true
 }
 
 // AddString implements ObjectEncoder interface to intercept trace_id
 func (enc *customConsoleEncoder) AddString(key, val string) {
-	if key == common.KeyTraceID {
-		enc.traceID = val
+	if enc.captureTopField(key, val) {
+		return
+	}
+	if enc.isBlock(val) {
+		enc.blocks = append(enc.blocks, prettyBlock{key: key, val: val})
 		return
 	}
 	enc.Encoder.AddString(key, val)
 }
 
+func (enc *customConsoleEncoder) AddInt64(key string, val int64) {
+	if enc.captureTopField(key, strconv.FormatInt(val, 10)) {
+		return
+	}
+	enc.Encoder.AddInt64(key, val)
+}
+
+func (enc *customConsoleEncoder) AddInt32(key string, val int32) {
+	if enc.captureTopField(key, strconv.FormatInt(int64(val), 10)) {
+		return
+	}
+	enc.Encoder.AddInt32(key, val)
+}
+
+func (enc *customConsoleEncoder) AddInt16(key string, val int16) {
+	if enc.captureTopField(key, strconv.FormatInt(int64(val), 10)) {
+		return
+	}
+	enc.Encoder.AddInt16(key, val)
+}
+
+func (enc *customConsoleEncoder) AddInt8(key string, val int8) {
+	if enc.captureTopField(key, strconv.FormatInt(int64(val), 10)) {
+		return
+	}
+	enc.Encoder.AddInt8(key, val)
+}
+
+func (enc *customConsoleEncoder) AddUint16(key string, val uint16) {
+	if enc.captureTopField(key, strconv.FormatUint(uint64(val), 10)) {
+		return
+	}
+	enc.Encoder.AddUint16(key, val)
+}
+
+func (enc *customConsoleEncoder) AddUint8(key string, val uint8) {
+	if enc.captureTopField(key, strconv.FormatUint(uint64(val), 10)) {
+		return
+	}
+	enc.Encoder.AddUint8(key, val)
+}
+
+func (enc *customConsoleEncoder) AddUint32(key string, val uint32) {
+	if enc.captureTopField(key, strconv.FormatUint(uint64(val), 10)) {
+		return
+	}
+	enc.Encoder.AddUint32(key, val)
+}
+
+func (enc *customConsoleEncoder) AddUint64(key string, val uint64) {
+	if enc.captureTopField(key, strconv.FormatUint(val, 10)) {
+		return
+	}
+	enc.Encoder.AddUint64(key, val)
+}
+
+func (enc *customConsoleEncoder) AddBool(key string, val bool) {
+	if enc.captureTopField(key, strconv.FormatBool(val)) {
+		return
+	}
+	enc.Encoder.AddBool(key, val)
+}
+
+func (enc *customConsoleEncoder) AddReflected(key string, val interface{}) error {
+	if enc.captureTopField(key, fmt.Sprint(val)) {
+		return nil
+	}
+	return enc.Encoder.AddReflected(key, val)
+}
+
 func (enc *customConsoleEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
-	// Extract trace_id from fields
+	slots := enc.slots
+	blocks := enc.blocks[:len(enc.blocks):len(enc.blocks)]
+	copied := false
+	fieldsEnc := enc.Encoder.Clone()
+
+	// Take the fields with a fixed position out of the fields, encode the
+	// others after the fields added with With
 	for _, field := range fields {
-		if field.Key == common.KeyTraceID && field.Type == zapcore.StringType {
-			enc.traceID = field.String
+		if enc.isSlot(field.Key) {
+			if val, ok := topFieldValue(field); ok {
+				if !copied {
+					slots = make(map[string]string, len(enc.slots)+1)
+					for k, v := range enc.slots {
+						slots[k] = v
+					}
+					copied = true
+				}
+				slots[field.Key] = val
+				continue
+			}
 		}
+		if field.Type == zapcore.StringType && enc.isBlock(field.String) {
+			blocks = append(blocks, prettyBlock{key: field.Key, val: field.String})
+			continue
+		}
+		field.AddTo(fieldsEnc)
+	}
+	multilineStack := enc.opts.multilineStack || enc.opts.pretty
+	if entry.Stack != "" && !multilineStack {
+		fieldsEnc.AddString(common.KeyStacktrace, entry.Stack)
 	}
+	fieldsBuf, err := fieldsEnc.EncodeEntry(zapcore.Entry{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer fieldsBuf.Free()
 
-	// Don't filter out any fields - keep them all for display
+	// Values that may come from user input are escaped so they can not
+	// forge extra lines
+	buf := bufferPool.Get()
+	switch {
+	case enc.layout != nil:
+		enc.appendLayout(buf, entry, slots, fieldsBuf.Bytes())
+	case enc.opts.pretty:
+		enc.appendPretty(buf, entry, slots, fieldsBuf.Bytes())
+	default:
+		enc.appendDefaultLayout(buf, entry, slots, fieldsBuf.Bytes())
+	}
+	enc.appendBlocks(buf, blocks)
 
-	// Determine trace ID to display
-	traceID := "[]"
-	if enc.traceID != "" {
-		traceID = "[" + enc.traceID + "]"
+	// Stack trace - indented block below the entry in multi-line mode
+	if entry.Stack != "" && multilineStack {
+		for _, line := range strings.Split(entry.Stack, "\n") {
+			buf.AppendString("\n    ")
+			enc.startColor(buf, colorDim)
+			buf.AppendString(line)
+			enc.endColor(buf)
+		}
 	}
 
-	// Build custom entry with trace_id in fixed position
-	buf := buffer.NewPool().Get()
+	buf.AppendString("\n")
+	return buf, nil
+}
 
+// appendDefaultLayout appends the built-in layout,
+// [time] [level] [name] caller [trace_id] [top fields...] msg {fields}
+func (enc *customConsoleEncoder) appendDefaultLayout(buf *buffer.Buffer, entry zapcore.Entry, slots map[string]string, fields []byte) {
 	// Time
 	buf.AppendString("[")
-	buf.AppendString(entry.Time.Format(DateTimeFormat))
+	buf.AppendString(enc.formatTime(entry.Time))
 	buf.AppendString("]")
 	buf.AppendString(" ")
 
@@ -74,31 +320,9 @@ func (enc *customConsoleEncoder) EncodeEntry(entry zapcore.Entry, fields []zapco
 
 	// Logger name
 	if entry.LoggerName != "" {
-		names := []rune(entry.LoggerName)
-		if len(names) > 0 && names[0] == '-' {
-			// Check if it's just "-" or has a suffix like "-.something"
-			if len(entry.LoggerName) > 2 && entry.LoggerName[1] == '.' {
-				// Has a named suffix, extract it
-				buf.AppendString("[")
-				buf.AppendString(entry.LoggerName[2:]) // Skip "-."
-				buf.AppendString("]")
-			} else {
-				// Just "-", show empty brackets
-				buf.AppendString("[]")
-			}
-		} else {
-			buf.AppendString("[")
-			// Remove first part before first dot
-			loggerName := entry.LoggerName
-			for i, c := range loggerName {
-				if c == '.' {
-					loggerName = loggerName[i+1:]
-					break
-				}
-			}
-			buf.AppendString(loggerName)
-			buf.AppendString("]")
-		}
+		buf.AppendString("[")
+		appendEscaped(buf, consoleLoggerName(entry.LoggerName))
+		buf.AppendString("]")
 		buf.AppendString(" ")
 	}
 
@@ -109,53 +333,128 @@ func (enc *customConsoleEncoder) EncodeEntry(entry zapcore.Entry, fields []zapco
 	}
 
 	// Trace ID - fixed position
-	buf.AppendString(traceID)
+	buf.AppendString("[")
+	appendEscaped(buf, slots[common.KeyTraceID])
+	buf.AppendString("]")
+
+	// Registered top fields - fixed positions after trace ID
+	for _, key := range common.TopFields() {
+		buf.AppendString(" [")
+		appendEscaped(buf, slots[key])
+		buf.AppendString("]")
+	}
 
 	// Message
 	buf.AppendString(" ")
-	buf.AppendString(entry.Message)
+	appendEscaped(buf, entry.Message)
 
-	// Other fields
-	if len(fields) > 0 {
+	// Other fields, as a valid JSON object
+	if len(fields) > len("{}") {
 		buf.AppendString(" ")
-		buf.AppendByte('{')
-		for i, field := range fields {
-			if i > 0 {
-				buf.AppendString(", ")
+		appendSpacedObject(buf, fields)
+	}
+}
+
+// appendLayout appends the entry rendered with the layout template. Empty
+// placeholders render as empty strings and trailing spaces are trimmed. With
+// colors, the time, level, caller and trace_id placeholders are colored.
+func (enc *customConsoleEncoder) appendLayout(buf *buffer.Buffer, entry zapcore.Entry, slots map[string]string, fields []byte) {
+	line := bufferPool.Get()
+	defer line.Free()
+
+	for _, part := range enc.layout.parts {
+		switch part.key {
+		case "":
+			line.AppendString(part.literal)
+		case layoutTime:
+			enc.startColor(line, colorDim)
+			appendPadded(line, enc.formatTime(entry.Time), part.width)
+			enc.endColor(line)
+		case layoutLevel:
+			enc.startColor(line, levelColor(entry.Level))
+			appendPadded(line, entry.Level.String(), part.width)
+			enc.endColor(line)
+		case layoutName:
+			appendPaddedEscaped(line, consoleLoggerName(entry.LoggerName), part.width)
+		case layoutCaller:
+			caller := ""
+			if entry.Caller.Defined {
+				caller = entry.Caller.TrimmedPath()
 			}
-			buf.AppendByte('"')
-			buf.AppendString(field.Key)
-			buf.AppendString(`": `)
-
-			switch field.Type {
-			case zapcore.StringType:
-				buf.AppendByte('"')
-				buf.AppendString(field.String)
-				buf.AppendByte('"')
-			case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type,
-				zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
-				fmt.Fprintf(buf, "%d", field.Integer)
-			case zapcore.Float64Type:
-				fmt.Fprintf(buf, "%f", float64(field.Integer))
-			case zapcore.Float32Type:
-				fmt.Fprintf(buf, "%f", float32(field.Integer))
-			case zapcore.BoolType:
-				if field.Integer == 1 {
-					buf.AppendString("true")
-				} else {
-					buf.AppendString("false")
-				}
-			default:
-				if data, err := json.Marshal(field.Interface); err == nil {
-					buf.Write(data)
-				} else {
-					fmt.Fprintf(buf, `"%v"`, field.Interface)
-				}
+			enc.startColor(line, colorDim)
+			appendPadded(line, caller, part.width)
+			enc.endColor(line)
+		case layoutMsg:
+			appendPaddedEscaped(line, entry.Message, part.width)
+		case layoutFields:
+			if len(fields) > len("{}") {
+				appendSpacedObject(line, fields)
 			}
+		case common.KeyTraceID:
+			enc.startColor(line, colorCyan)
+			appendPaddedEscaped(line, slots[part.key], part.width)
+			enc.endColor(line)
+		default:
+			appendPaddedEscaped(line, slots[part.key], part.width)
 		}
-		buf.AppendByte('}')
 	}
+	buf.Write(bytes.TrimRight(line.Bytes(), " "))
+}
 
-	buf.AppendString("\n")
-	return buf, nil
+func (enc *customConsoleEncoder) formatTime(t time.Time) string {
+	return timestampFormat{format: enc.opts.timeFormat, loc: enc.opts.timezone}.String(t)
+}
+
+// appendPaddedEscaped appends s escaped and padded to width runes
+func appendPaddedEscaped(buf *buffer.Buffer, s string, width int) {
+	if width == 0 {
+		appendEscaped(buf, s)
+		return
+	}
+	escaped := bufferPool.Get()
+	defer escaped.Free()
+	appendEscaped(escaped, s)
+	appendPadded(buf, escaped.String(), width)
+}
+
+// consoleLoggerName returns the displayed logger name: the default name "-"
+// is empty and the root part of a named logger is removed
+func consoleLoggerName(name string) string {
+	if strings.HasPrefix(name, "-") {
+		// Has a named suffix like "-.something", extract it
+		if len(name) > 2 && name[1] == '.' {
+			return name[2:]
+		}
+		return ""
+	}
+	// Remove first part before first dot
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// topFieldValue renders a top field value for its fixed position
+func topFieldValue(field zapcore.Field) (string, bool) {
+	switch field.Type {
+	case zapcore.StringType:
+		return field.String, true
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return strconv.FormatInt(field.Integer, 10), true
+	case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
+		return strconv.FormatUint(uint64(field.Integer), 10), true
+	case zapcore.Float64Type:
+		return strconv.FormatFloat(math.Float64frombits(uint64(field.Integer)), 'f', -1, 64), true
+	case zapcore.Float32Type:
+		return strconv.FormatFloat(float64(math.Float32frombits(uint32(field.Integer))), 'f', -1, 32), true
+	case zapcore.BoolType:
+		return strconv.FormatBool(field.Integer == 1), true
+	case zapcore.StringerType:
+		if s, ok := field.Interface.(fmt.Stringer); ok {
+			return s.String(), true
+		}
+	case zapcore.ReflectType:
+		return fmt.Sprint(field.Interface), true
+	}
+	return "", false
 }
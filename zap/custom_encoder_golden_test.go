@@ -0,0 +1,101 @@
+package zap
+
+import (
+	"errors"
+	"flag"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+type point struct{ X, Y int }
+
+func (p point) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt("x", p.X)
+	enc.AddInt("y", p.Y)
+	return nil
+}
+
+// goldenEntries are encoded in order into testdata/console_fields.golden
+var goldenEntries = []struct {
+	name   string
+	with   []zapcore.Field
+	fields []zapcore.Field
+}{
+	{name: "no fields"},
+	{name: "scalars", fields: []zapcore.Field{
+		zap.String("string", "v"), zap.Int("int", -1), zap.Int8("int8", 8), zap.Int16("int16", 16),
+		zap.Int32("int32", 32), zap.Int64("int64", math.MinInt64), zap.Uint("uint", 1), zap.Uint8("uint8", 8),
+		zap.Uint16("uint16", 16), zap.Uint32("uint32", 32), zap.Uint64("uint64", math.MaxUint64),
+		zap.Uintptr("uintptr", 0xff), zap.Bool("true", true), zap.Bool("false", false),
+	}},
+	{name: "floats", fields: []zapcore.Field{
+		zap.Float64("float64", 1.25), zap.Float32("float32", 0.5), zap.Float64("nan", math.NaN()),
+		zap.Float64("inf", math.Inf(1)), zap.Complex128("complex128", complex(1, -2)),
+		zap.Complex64("complex64", complex(0.5, 1)),
+	}},
+	{name: "time and bytes", fields: []zapcore.Field{
+		zap.Duration("duration", 1500*time.Millisecond), zap.Time("time", time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC)),
+		zap.ByteString("bytestring", []byte("raw \"bytes\"")), zap.Binary("binary", []byte{0, 1, 2}),
+	}},
+	{name: "marshalers", fields: []zapcore.Field{
+		zap.Object("object", point{1, 2}), zap.Ints("ints", []int{1, 2}), zap.Strings("strings", []string{"a", "b"}),
+		zap.Stringer("stringer", net.IPv4(10, 0, 0, 1)), zap.Any("reflect", map[string]int{"k": 1}),
+		zap.NamedError("named_error", errors.New("boom")), zap.Skip(),
+	}},
+	{name: "namespace", fields: []zapcore.Field{zap.String("outer", "o"), zap.Namespace("ns"), zap.String("inner", "i")}},
+	{
+		name:   "with fields",
+		with:   []zapcore.Field{zap.String("svc", "api"), zap.Int("shard", 3), zap.String(common.KeyTraceID, "with-trace")},
+		fields: []zapcore.Field{zap.Bool("call", true)},
+	},
+	{name: "per call trace_id", fields: []zapcore.Field{zap.String(common.KeyTraceID, "call-trace"), zap.String("k", "v")}},
+}
+
+func TestCustomConsoleEncoder_Golden(t *testing.T) {
+	golden := filepath.Join("testdata", "console_fields.golden")
+	var out []byte
+
+	for _, entry := range goldenEntries {
+		enc := newCustomConsoleEncoder(newEncoderConfig(), consoleOptions{})
+		if len(entry.with) > 0 {
+			enc = enc.Clone()
+			for _, field := range entry.with {
+				field.AddTo(enc)
+			}
+		}
+		buf, err := enc.EncodeEntry(zapcore.Entry{
+			Level:      zapcore.InfoLevel,
+			Time:       time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC),
+			LoggerName: "-",
+			Message:    entry.name,
+		}, entry.fields)
+		if err != nil {
+			t.Fatalf("EncodeEntry(%s) error = %v", entry.name, err)
+		}
+		out = append(out, buf.Bytes()...)
+		buf.Free()
+	}
+
+	if *update {
+		if err := os.WriteFile(golden, out, 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read golden file, run with -update to create it: %v", err)
+	}
+	if string(out) != string(want) {
+		t.Errorf("console output differs from %s:\ngot:\n%s\nwant:\n%s", golden, out, want)
+	}
+}
@@ -0,0 +1,93 @@
+package zap
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+)
+
+func TestCustomConsoleEncoder_TopFields(t *testing.T) {
+	common.RegisterTopField("tenant_id")
+	common.RegisterTopField("shard")
+	defer common.UnregisterTopField("tenant_id")
+	defer common.UnregisterTopField("shard")
+
+	logger, logFile := newFileLogger(t, common.WithConsoleEncoding(), common.WithLevel(common.DebugLevel))
+	logger.WithFields(map[string]interface{}{
+		common.KeyTraceID: "trace-1",
+		"tenant_id":       "acme",
+		"shard":           int64(3),
+	}).Info("with top fields")
+	logger.WithField(common.KeyTraceID, "trace-2").Info("without top fields")
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, logFile)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %v", len(lines), lines)
+	}
+	if !strings.Contains(lines[0], "[trace-1] [acme] [3] with top fields") {
+		t.Errorf("top fields should follow trace_id in registration order, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "[trace-2] [] [] without top fields") {
+		t.Errorf("missing top fields should keep empty slots, got %q", lines[1])
+	}
+}
+
+func TestCustomConsoleEncoder_TraceIDNotSticky(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithConsoleEncoding(), common.WithLevel(common.DebugLevel))
+	logger.Infow("first", common.KeyTraceID, "trace-a")
+	logger.Info("second")
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, logFile)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %v", len(lines), lines)
+	}
+	if strings.Contains(lines[1], "trace-a") {
+		t.Errorf("trace_id of a previous entry should not leak, got %q", lines[1])
+	}
+}
+
+func TestCustomConsoleEncoder_InjectionProtection(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithConsoleEncoding(), common.WithLevel(common.DebugLevel))
+	forged := "bob\n[2020-01-01 00:00:00.000] [error] [] main.go:1 [] forged"
+
+	logger.Infow("login "+forged, common.KeyTraceID, "t\r\n1", "user", forged, "quote", `say "hi"`, "ratio", 0.25,
+		"sep", "a\u2028b\u0085c")
+
+	out := readFile(t, logFile)
+	if strings.ContainsAny(out, "\u2028\u0085") {
+		t.Errorf("line separators and C1 controls in fields should be escaped, got %q", out)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("values should not be able to add lines, got %q", out)
+	}
+	if !strings.Contains(lines[0], `[t\r\n1] login bob\n[2020-01-01`) {
+		t.Errorf("message and trace_id should be escaped, got %q", lines[0])
+	}
+
+	object := lines[0][strings.Index(lines[0], "{"):]
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(object), &fields); err != nil {
+		t.Fatalf("the field object should be valid JSON, got %q: %v", object, err)
+	}
+	if fields["user"] != forged || fields["quote"] != `say "hi"` || fields["ratio"] != 0.25 ||
+		fields["sep"] != "a\u2028b\u0085c" {
+		t.Errorf("field values should round trip, got %v", fields)
+	}
+}
+
+func TestCustomConsoleEncoder_EscapesQuotesAndBackslashes(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithConsoleEncoding())
+
+	logger.Infow(`path C:\new {"admin": true}`, common.KeyTraceID, `t"1\`)
+
+	line := strings.TrimSuffix(readFile(t, logFile), "\n")
+	if !strings.Contains(line, `[t\"1\\] path C:\\new {\"admin\": true}`) {
+		t.Errorf("backslashes and quotes in the message and slots should be escaped, got %q", line)
+	}
+	if strings.HasSuffix(line, `{"admin": true}`) {
+		t.Errorf("the message should not pass for a field object, got %q", line)
+	}
+}
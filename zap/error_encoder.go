@@ -0,0 +1,172 @@
+package zap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// maxErrorDepth bounds the nesting of joined errors
+	maxErrorDepth = 8
+	// maxErrorCauses bounds the errors.Unwrap chain of an error, which may
+	// even be a cycle
+	maxErrorCauses = 32
+)
+
+// errorObject encodes an error as an object with its message, concrete type,
+// unwrap chain, joined branches, stack and attached fields
+type errorObject struct {
+	err   error
+	depth int
+}
+
+var _ zapcore.ObjectMarshaler = errorObject{}
+
+func (e errorObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	d := describeError(e.err, e.depth)
+	enc.AddString("msg", d.msg)
+	enc.AddString("type", d.typ)
+	if len(d.causes) > 0 {
+		if err := enc.AddArray("causes", errorArray(d.causes)); err != nil {
+			return err
+		}
+	}
+	if len(d.branches) > 0 {
+		if err := enc.AddArray("errors", errorArray(d.branches)); err != nil {
+			return err
+		}
+	}
+	if d.stack != "" {
+		enc.AddString("stack", d.stack)
+	}
+	if len(d.fields) > 0 {
+		return enc.AddReflected("fields", d.fields)
+	}
+	return nil
+}
+
+type errorArray []errorObject
+
+func (a errorArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, e := range a {
+		if err := enc.AppendObject(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errorDescription is an error flattened for encoding. Causes are the first
+// maxErrorCauses errors of the errors.Unwrap chain and are described without
+// their own chain.
+type errorDescription struct {
+	msg      string
+	typ      string
+	causes   []errorObject
+	branches []errorObject
+	stack    string
+	fields   map[string]interface{}
+}
+
+func describeError(err error, depth int) errorDescription {
+	// The methods of a typed nil error may dereference their receiver
+	if nilError(err) {
+		return errorDescription{msg: "<nil>", typ: fmt.Sprintf("%T", err)}
+	}
+	d := errorDescription{msg: err.Error(), typ: fmt.Sprintf("%T", err)}
+	if depth >= maxErrorDepth {
+		return d
+	}
+
+	// Fields of the outer errors win, the stack of the innermost error wins
+	for i, cur := 0, err; cur != nil; i, cur = i+1, errors.Unwrap(cur) {
+		if i > 0 {
+			if len(d.causes) >= maxErrorCauses {
+				break
+			}
+			d.causes = append(d.causes, errorObject{err: cur, depth: maxErrorDepth})
+			if nilError(cur) {
+				break
+			}
+		}
+		if fielder, ok := cur.(common.ErrorFielder); ok {
+			for k, v := range fielder.LogFields() {
+				if d.fields == nil {
+					d.fields = map[string]interface{}{}
+				}
+				if _, exists := d.fields[k]; !exists {
+					d.fields[k] = v
+				}
+			}
+		}
+		if stack := errorStack(cur); stack != "" {
+			d.stack = stack
+		}
+		if joined, ok := cur.(interface{ Unwrap() []error }); ok {
+			for _, branch := range joined.Unwrap() {
+				if branch != nil {
+					d.branches = append(d.branches, errorObject{err: branch, depth: depth + 1})
+				}
+			}
+			break
+		}
+	}
+	return d
+}
+
+// rewrittenError replaces an error field with a rewritten message and
+// verbose form
+type rewrittenError struct {
+	msg     string
+	verbose string
+}
+
+func (e rewrittenError) Error() string {
+	return e.msg
+}
+
+func (e rewrittenError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') && e.verbose != "" {
+		_, _ = io.WriteString(s, e.verbose)
+		return
+	}
+	_, _ = io.WriteString(s, e.msg)
+}
+
+// errorStack formats the stack of errors with a pkg/errors style
+// StackTrace() method, whatever its result type
+func errorStack(err error) string {
+	if nilError(err) {
+		return ""
+	}
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return ""
+	}
+	out := method.Call(nil)[0]
+	if out.Kind() == reflect.String {
+		return out.String()
+	}
+	if (out.Kind() == reflect.Slice || out.Kind() == reflect.Ptr || out.Kind() == reflect.Interface) && out.IsNil() {
+		return ""
+	}
+	return fmt.Sprintf("%+v", out.Interface())
+}
+
+// nilError reports whether err is nil or an interface holding a nil pointer,
+// map, slice, func or channel
+func nilError(err error) bool {
+	if err == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(err); v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package zap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+)
+
+type fieldsError struct {
+	msg    string
+	fields map[string]interface{}
+}
+
+func (e *fieldsError) Error() string                     { return e.msg }
+func (e *fieldsError) LogFields() map[string]interface{} { return e.fields }
+
+// stackError mimics pkg/errors, whose StackTrace() returns a named slice
+// type implementing fmt.Formatter
+type stackError struct {
+	msg string
+}
+
+type fakeStackTrace []string
+
+func (s fakeStackTrace) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, strings.Join(s, "\n"))
+}
+
+func (e *stackError) Error() string              { return e.msg }
+func (e *stackError) StackTrace() fakeStackTrace { return fakeStackTrace{"main.origin", "\tmain.go:7"} }
+
+func logErrorJSON(t *testing.T, err error) map[string]interface{} {
+	logger, logFile := newFileLogger(t)
+	logger.WithError(err).Error("failed")
+
+	entry := readJSONEntry(t, logFile)
+	obj, ok := entry[common.KeyError].(map[string]interface{})
+	if !ok {
+		t.Fatalf("error should be encoded as an object, got %v", entry[common.KeyError])
+	}
+	return obj
+}
+
+func TestErrorEncoding_Chain(t *testing.T) {
+	root := &stackError{msg: "connection refused"}
+	err := fmt.Errorf("load profile: %w", &wrapped{msg: "query users", cause: root,
+		fields: map[string]interface{}{"table": "users"}})
+
+	obj := logErrorJSON(t, err)
+	if obj["msg"] != "load profile: query users: connection refused" || obj["type"] != "*fmt.wrapError" {
+		t.Errorf("unexpected msg or type: %v", obj)
+	}
+	causes, _ := obj["causes"].([]interface{})
+	if len(causes) != 2 {
+		t.Fatalf("got %d causes, want 2: %v", len(causes), obj["causes"])
+	}
+	if last := causes[1].(map[string]interface{}); last["msg"] != "connection refused" || last["type"] != "*zap.stackError" {
+		t.Errorf("last cause should be the root error, got %v", last)
+	}
+	if obj["stack"] != "main.origin\n\tmain.go:7" {
+		t.Errorf("stack of the root error should be encoded, got %v", obj["stack"])
+	}
+	if fields, _ := obj["fields"].(map[string]interface{}); fields["table"] != "users" {
+		t.Errorf("LogFields() should be encoded, got %v", obj["fields"])
+	}
+}
+
+type wrapped struct {
+	msg    string
+	cause  error
+	fields map[string]interface{}
+}
+
+func (e *wrapped) Error() string                     { return e.msg + ": " + e.cause.Error() }
+func (e *wrapped) Unwrap() error                     { return e.cause }
+func (e *wrapped) LogFields() map[string]interface{} { return e.fields }
+
+func TestErrorEncoding_Join(t *testing.T) {
+	err := fmt.Errorf("cleanup: %w", errors.Join(errors.New("close file"), fmt.Errorf("remove dir: %w", errors.New("busy"))))
+
+	obj := logErrorJSON(t, err)
+	causes, _ := obj["causes"].([]interface{})
+	if len(causes) != 1 || causes[0].(map[string]interface{})["type"] != "*errors.joinError" {
+		t.Errorf("the chain should stop at the joined error, got %v", obj["causes"])
+	}
+	branches, _ := obj["errors"].([]interface{})
+	if len(branches) != 2 {
+		t.Fatalf("got %d branches, want 2: %v", len(branches), obj["errors"])
+	}
+	second := branches[1].(map[string]interface{})
+	if second["msg"] != "remove dir: busy" || len(second["causes"].([]interface{})) != 1 {
+		t.Errorf("branches should be encoded with their own chain, got %v", second)
+	}
+}
+
+// derefStackError dereferences its receiver in StackTrace() but not in
+// Error()
+type derefStackError struct {
+	frames []string
+}
+
+func (e *derefStackError) Error() string              { return "deref stack" }
+func (e *derefStackError) StackTrace() fakeStackTrace { return fakeStackTrace(e.frames) }
+
+func TestErrorEncoding_TypedNil(t *testing.T) {
+	obj := logErrorJSON(t, (*stackError)(nil))
+	if obj["msg"] != "<nil>" || obj["type"] != "*zap.stackError" {
+		t.Errorf("a typed nil error should be encoded as <nil>, got %v", obj)
+	}
+
+	obj = logErrorJSON(t, fmt.Errorf("load: %w", (*derefStackError)(nil)))
+	causes, _ := obj["causes"].([]interface{})
+	if len(causes) != 1 || causes[0].(map[string]interface{})["msg"] != "<nil>" || obj["stack"] != nil {
+		t.Errorf("a typed nil cause should be encoded as <nil> without its stack, got %v", obj)
+	}
+}
+
+// loopError unwraps to itself
+type loopError struct{}
+
+func (loopError) Error() string { return "loop" }
+func (loopError) Unwrap() error { return loopError{} }
+
+func TestErrorEncoding_CausesLimit(t *testing.T) {
+	var long error = errors.New("root")
+	for i := 0; i < 2*maxErrorCauses; i++ {
+		long = fmt.Errorf("layer %d: %w", i, long)
+	}
+
+	for _, err := range []error{long, loopError{}} {
+		obj := logErrorJSON(t, err)
+		if causes, _ := obj["causes"].([]interface{}); len(causes) != maxErrorCauses {
+			t.Errorf("got %d causes of %T, want %d", len(causes), err, maxErrorCauses)
+		}
+	}
+}
+
+func TestErrorEncoding_Console(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithConsoleEncoding())
+	logger.WithError(&fieldsError{msg: "denied", fields: map[string]interface{}{"user": "bob"}}).Error("failed")
+
+	out := readFile(t, logFile)
+	if !strings.Contains(out, `{"error": {"msg":"denied","type":"*zap.fieldsError","fields":{"user":"bob"}}}`) {
+		t.Errorf("console output should contain the error object, got %q", out)
+	}
+}
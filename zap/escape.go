@@ -0,0 +1,142 @@
+package zap
+
+import (
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// appendEscaped appends s with backslashes, quotes, newlines, tabs and other
+// control characters escaped, so values written outside quotes can not
+// start a forged line or pass for escaped text or a field object
+func appendEscaped(buf *buffer.Buffer, s string) {
+	start := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !needsEscape(r) && r != '\\' && r != '"' && (r != utf8.RuneError || size != 1) {
+			i += size
+			continue
+		}
+		buf.AppendString(s[start:i])
+		if r == '\\' || r == '"' {
+			buf.AppendByte('\\')
+			buf.AppendByte(byte(r))
+		} else {
+			var escaped [6]byte
+			buf.Write(appendEscapedRune(escaped[:0], r, size))
+		}
+		i += size
+		start = i
+	}
+	buf.AppendString(s[start:])
+}
+
+func needsEscape(r rune) bool {
+	return r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0) || r == '\u2028' || r == '\u2029'
+}
+
+// appendEscapedRune appends the escape sequence of r, size is its length in
+// the string so invalid bytes become \ufffd
+func appendEscapedRune(dst []byte, r rune, size int) []byte {
+	switch {
+	case r == '\n':
+		return append(dst, `\n`...)
+	case r == '\r':
+		return append(dst, `\r`...)
+	case r == '\t':
+		return append(dst, `\t`...)
+	case r == utf8.RuneError && size == 1:
+		return append(dst, `\ufffd`...)
+	}
+	dst = append(dst, `\u`...)
+	for shift := 12; shift >= 0; shift -= 4 {
+		dst = append(dst, hexDigits[(r>>uint(shift))&0xf])
+	}
+	return dst
+}
+
+// appendJSONString appends s quoted and escaped like zap's JSON encoder:
+// quotes, backslashes, control characters below 0x20 and invalid UTF-8 are
+// escaped
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r >= 0x20 && r != '\\' && r != '"' && (r != utf8.RuneError || size != 1) {
+			i += size
+			continue
+		}
+		dst = append(dst, s[start:i]...)
+		if r == '\\' || r == '"' {
+			dst = append(dst, '\\', byte(r))
+		} else {
+			dst = appendEscapedRune(dst, r, size)
+		}
+		i += size
+		start = i
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+// appendJSONFloat appends f like zap's JSON encoder, with NaN and infinities
+// as strings
+func appendJSONFloat(dst []byte, f float64, bitSize int) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(dst, `"NaN"`...)
+	case math.IsInf(f, 1):
+		return append(dst, `"+Inf"`...)
+	case math.IsInf(f, -1):
+		return append(dst, `"-Inf"`...)
+	}
+	return strconv.AppendFloat(dst, f, 'f', -1, bitSize)
+}
+
+// appendSpacedObject appends the JSON object obj with a space after the
+// colons and commas of its top level, the console layout of the fields.
+// C1 controls and line separators, which JSON allows raw in strings, are
+// escaped like appendEscaped does.
+func appendSpacedObject(buf *buffer.Buffer, obj []byte) {
+	depth := 0
+	inString, escaped := false, false
+	for i := 0; i < len(obj); i++ {
+		b := obj[i]
+		if b >= utf8.RuneSelf {
+			r, size := utf8.DecodeRune(obj[i:])
+			if needsEscape(r) || (r == utf8.RuneError && size == 1) {
+				var escaped [6]byte
+				buf.Write(appendEscapedRune(escaped[:0], r, size))
+			} else {
+				buf.Write(obj[i : i+size])
+			}
+			i += size - 1
+			continue
+		}
+		buf.AppendByte(b)
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+		case b == '"':
+			inString = true
+		case b == '{' || b == '[':
+			depth++
+		case b == '}' || b == ']':
+			depth--
+		case (b == ':' || b == ',') && depth == 1:
+			buf.AppendByte(' ')
+		}
+	}
+}
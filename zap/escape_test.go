@@ -0,0 +1,68 @@
+package zap
+
+import (
+	"testing"
+
+	"go.uber.org/zap/buffer"
+)
+
+func TestAppendEscaped(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain 测试 🚀", "plain 测试 🚀"},
+		{"a\nb\r\tc", `a\nb\r\tc`},
+		{"bell\x07\x7f", `bell\u0007\u007f`},
+		{"line\u2028sep", `line\u2028sep`},
+		{"bad\xffutf8", `bad\ufffdutf8`},
+		{`quote " and \ escaped`, `quote \" and \\ escaped`},
+		{`literal \n`, `literal \\n`},
+	}
+
+	for _, tt := range tests {
+		buf := buffer.NewPool().Get()
+		appendEscaped(buf, tt.in)
+		if got := buf.String(); got != tt.want {
+			t.Errorf("appendEscaped(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAppendJSONString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain 测试", `"plain 测试"`},
+		{"a\nb\r\tc\x01", `"a\nb\r\tc\u0001"`},
+		{`quote " back \`, `"quote \" back \\"`},
+		{"bad\xffutf8", `"bad\ufffdutf8"`},
+	}
+
+	for _, tt := range tests {
+		if got := string(appendJSONString(nil, tt.in)); got != tt.want {
+			t.Errorf("appendJSONString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAppendSpacedObject(t *testing.T) {
+	buf := buffer.NewPool().Get()
+	appendSpacedObject(buf, []byte(`{"a":1,"b":{"c":[1,2]},"d":"x:\",y"}`))
+
+	want := `{"a": 1, "b": {"c":[1,2]}, "d": "x:\",y"}`
+	if got := buf.String(); got != want {
+		t.Errorf("appendSpacedObject() = %s, want %s", got, want)
+	}
+}
+
+func TestAppendSpacedObject_EscapesSeparators(t *testing.T) {
+	buf := buffer.NewPool().Get()
+	appendSpacedObject(buf, []byte("{\"k\":\"a\u0085b\u2028c\u2029d é\"}"))
+
+	want := `{"k": "a\u0085b\u2028c\u2029d é"}`
+	if got := buf.String(); got != want {
+		t.Errorf("appendSpacedObject() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,44 @@
+package zap
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+)
+
+// newFileLogger returns a JSON logger writing to a file in a temporary
+// directory of t, configured further by withFuncs, and the path of the file
+func newFileLogger(t *testing.T, withFuncs ...common.WithFunc) (*Logger, string) {
+	t.Helper()
+	logFile := filepath.Join(t.TempDir(), "test.log")
+	withFuncs = append([]common.WithFunc{common.WithOutputPath(logFile), common.WithJsonEncoding()}, withFuncs...)
+	logger, err := NewLogger(common.Options{}, withFuncs...)
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	return logger, logFile
+}
+
+// readFile returns the content of the file at path
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	return string(content)
+}
+
+// readJSONEntry decodes the single JSON entry in logFile
+func readJSONEntry(t *testing.T, logFile string) map[string]interface{} {
+	t.Helper()
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(readFile(t, logFile))), &entry); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	return entry
+}
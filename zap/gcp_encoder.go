@@ -0,0 +1,422 @@
+package zap
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// Special keys of the Cloud Logging structured format, see
+// https://cloud.google.com/logging/docs/structured-logging
+const (
+	gcpSeverityKey       = "severity"
+	gcpMessageKey        = "message"
+	gcpTimeKey           = "timestamp"
+	gcpLoggerKey         = "logger"
+	gcpStacktraceKey     = "stack_trace"
+	gcpTraceKey          = "logging.googleapis.com/trace"
+	gcpSpanIDKey         = "logging.googleapis.com/spanId"
+	gcpSourceLocationKey = "logging.googleapis.com/sourceLocation"
+	gcpHTTPRequestKey    = "httpRequest"
+)
+
+// gcpHTTPKeys are the HTTP middleware fields moved into httpRequest, in the
+// order they are written back when an entry is not an access log or a value
+// does not convert
+var gcpHTTPKeys = []string{
+	common.KeyHTTPMethod, common.KeyHTTPStatus, common.KeyHTTPBytes, common.KeyHTTPLatencyMs,
+	common.KeyHTTPUserAgent, common.KeyPathname, common.KeyClientIP,
+}
+
+// gcpCapturedKeys are the fields the gcp encoder takes out of the payload
+var gcpCapturedKeys = func() map[string]bool {
+	keys := map[string]bool{common.KeyTraceID: true, common.KeySpanID: true, common.KeyAccessLog: true}
+	for _, key := range gcpHTTPKeys {
+		keys[key] = true
+	}
+	return keys
+}()
+
+// gcpEncoderName returns the name of the registered gcp encoder for projectID
+func gcpEncoderName(projectID string) (string, error) {
+	name := fmt.Sprintf("%s-%q", common.EncodeGCP, projectID)
+	err := registerEncoder(name, func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return newGCPEncoder(cfg, projectID), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// gcpEncoder writes entries in the Cloud Logging structured JSON format:
+// severity, message and timestamp, trace and span IDs in their special keys,
+// the caller as sourceLocation and the HTTP middleware fields as httpRequest
+type gcpEncoder struct {
+	zapcore.Encoder
+	projectID string
+	// captured are the special fields added with With, whatever their type,
+	// the same as the fields of an entry
+	captured map[string]zapcore.Field
+}
+
+func newGCPEncoder(cfg zapcore.EncoderConfig, projectID string) *gcpEncoder {
+	cfg.TimeKey = gcpTimeKey
+	cfg.LevelKey = gcpSeverityKey
+	cfg.MessageKey = gcpMessageKey
+	cfg.StacktraceKey = gcpStacktraceKey
+	cfg.NameKey = zapcore.OmitKey
+	cfg.CallerKey = zapcore.OmitKey
+	cfg.FunctionKey = zapcore.OmitKey
+	cfg.EncodeLevel = gcpSeverityEncoder
+	return &gcpEncoder{
+		Encoder:   zapcore.NewJSONEncoder(cfg),
+		projectID: projectID,
+	}
+}
+
+// gcpSeverity maps a level to its Cloud Logging severity
+func gcpSeverity(level zapcore.Level) string {
+	switch level {
+	case zapcore.DebugLevel:
+		return "DEBUG"
+	case zapcore.InfoLevel:
+		return "INFO"
+	case zapcore.WarnLevel:
+		return "WARNING"
+	case zapcore.ErrorLevel:
+		return "ERROR"
+	case zapcore.DPanicLevel:
+		return "CRITICAL"
+	case zapcore.PanicLevel:
+		return "ALERT"
+	case zapcore.FatalLevel:
+		return "EMERGENCY"
+	}
+	return "DEFAULT"
+}
+
+func gcpSeverityEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendString(gcpSeverity(level))
+}
+
+func (enc *gcpEncoder) Clone() zapcore.Encoder {
+	captured := make(map[string]zapcore.Field, len(enc.captured))
+	for k, v := range enc.captured {
+		captured[k] = v
+	}
+	return &gcpEncoder{
+		Encoder:   enc.Encoder.Clone(),
+		projectID: enc.projectID,
+		captured:  captured,
+	}
+}
+
+// capture stores a special field added with With
+func (enc *gcpEncoder) capture(field zapcore.Field) bool {
+	if !gcpCapturedKeys[field.Key] {
+		return false
+	}
+	if enc.captured == nil {
+		enc.captured = map[string]zapcore.Field{}
+	}
+	enc.captured[field.Key] = field
+	return true
+}
+
+func (enc *gcpEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	if enc.capture(zap.Array(key, arr)) {
+		return nil
+	}
+	return enc.Encoder.AddArray(key, arr)
+}
+
+func (enc *gcpEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	if enc.capture(zap.Object(key, obj)) {
+		return nil
+	}
+	return enc.Encoder.AddObject(key, obj)
+}
+
+func (enc *gcpEncoder) AddBinary(key string, val []byte) {
+	if enc.capture(zap.Binary(key, val)) {
+		return
+	}
+	enc.Encoder.AddBinary(key, val)
+}
+
+func (enc *gcpEncoder) AddByteString(key string, val []byte) {
+	if enc.capture(zap.ByteString(key, val)) {
+		return
+	}
+	enc.Encoder.AddByteString(key, val)
+}
+
+func (enc *gcpEncoder) AddBool(key string, val bool) {
+	if enc.capture(zap.Bool(key, val)) {
+		return
+	}
+	enc.Encoder.AddBool(key, val)
+}
+
+func (enc *gcpEncoder) AddComplex128(key string, val complex128) {
+	if enc.capture(zap.Complex128(key, val)) {
+		return
+	}
+	enc.Encoder.AddComplex128(key, val)
+}
+
+func (enc *gcpEncoder) AddComplex64(key string, val complex64) {
+	if enc.capture(zap.Complex64(key, val)) {
+		return
+	}
+	enc.Encoder.AddComplex64(key, val)
+}
+
+func (enc *gcpEncoder) AddDuration(key string, val time.Duration) {
+	if enc.capture(zap.Duration(key, val)) {
+		return
+	}
+	enc.Encoder.AddDuration(key, val)
+}
+
+func (enc *gcpEncoder) AddFloat64(key string, val float64) {
+	if enc.capture(zap.Float64(key, val)) {
+		return
+	}
+	enc.Encoder.AddFloat64(key, val)
+}
+
+func (enc *gcpEncoder) AddFloat32(key string, val float32) {
+	if enc.capture(zap.Float32(key, val)) {
+		return
+	}
+	enc.Encoder.AddFloat32(key, val)
+}
+
+func (enc *gcpEncoder) AddInt(key string, val int) {
+	if enc.capture(zap.Int(key, val)) {
+		return
+	}
+	enc.Encoder.AddInt(key, val)
+}
+
+func (enc *gcpEncoder) AddInt64(key string, val int64) {
+	if enc.capture(zap.Int64(key, val)) {
+		return
+	}
+	enc.Encoder.AddInt64(key, val)
+}
+
+func (enc *gcpEncoder) AddInt32(key string, val int32) {
+	if enc.capture(zap.Int32(key, val)) {
+		return
+	}
+	enc.Encoder.AddInt32(key, val)
+}
+
+func (enc *gcpEncoder) AddInt16(key string, val int16) {
+	if enc.capture(zap.Int16(key, val)) {
+		return
+	}
+	enc.Encoder.AddInt16(key, val)
+}
+
+func (enc *gcpEncoder) AddInt8(key string, val int8) {
+	if enc.capture(zap.Int8(key, val)) {
+		return
+	}
+	enc.Encoder.AddInt8(key, val)
+}
+
+func (enc *gcpEncoder) AddString(key, val string) {
+	if enc.capture(zap.String(key, val)) {
+		return
+	}
+	enc.Encoder.AddString(key, val)
+}
+
+func (enc *gcpEncoder) AddTime(key string, val time.Time) {
+	if enc.capture(zap.Time(key, val)) {
+		return
+	}
+	enc.Encoder.AddTime(key, val)
+}
+
+func (enc *gcpEncoder) AddUint(key string, val uint) {
+	if enc.capture(zap.Uint(key, val)) {
+		return
+	}
+	enc.Encoder.AddUint(key, val)
+}
+
+func (enc *gcpEncoder) AddUint64(key string, val uint64) {
+	if enc.capture(zap.Uint64(key, val)) {
+		return
+	}
+	enc.Encoder.AddUint64(key, val)
+}
+
+func (enc *gcpEncoder) AddUint32(key string, val uint32) {
+	if enc.capture(zap.Uint32(key, val)) {
+		return
+	}
+	enc.Encoder.AddUint32(key, val)
+}
+
+func (enc *gcpEncoder) AddUint16(key string, val uint16) {
+	if enc.capture(zap.Uint16(key, val)) {
+		return
+	}
+	enc.Encoder.AddUint16(key, val)
+}
+
+func (enc *gcpEncoder) AddUint8(key string, val uint8) {
+	if enc.capture(zap.Uint8(key, val)) {
+		return
+	}
+	enc.Encoder.AddUint8(key, val)
+}
+
+func (enc *gcpEncoder) AddUintptr(key string, val uintptr) {
+	if enc.capture(zap.Uintptr(key, val)) {
+		return
+	}
+	enc.Encoder.AddUintptr(key, val)
+}
+
+func (enc *gcpEncoder) AddReflected(key string, val interface{}) error {
+	if enc.capture(zap.Reflect(key, val)) {
+		return nil
+	}
+	return enc.Encoder.AddReflected(key, val)
+}
+
+func (enc *gcpEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := enc.Clone().(*gcpEncoder)
+	for _, field := range fields {
+		if gcpCapturedKeys[field.Key] {
+			final.captured[field.Key] = field
+			continue
+		}
+		field.AddTo(final.Encoder)
+	}
+	captured := final.captured
+
+	if field, ok := captured[common.KeyTraceID]; ok {
+		if traceID, _ := topFieldValue(field); traceID != "" && enc.projectID != "" {
+			final.Encoder.AddString(gcpTraceKey, "projects/"+enc.projectID+"/traces/"+traceID)
+		} else {
+			field.AddTo(final.Encoder)
+		}
+	}
+	if field, ok := captured[common.KeySpanID]; ok {
+		spanID, _ := topFieldValue(field)
+		final.Encoder.AddString(gcpSpanIDKey, spanID)
+	}
+
+	// Only the entries the HTTP middleware marks as access logs describe a
+	// request, the marker itself is dropped
+	marker, marked := captured[common.KeyAccessLog]
+	accessLog := marked && marker.Type == zapcore.BoolType && marker.Integer == 1
+	if marked && !accessLog {
+		marker.AddTo(final.Encoder)
+	}
+	request := gcpHTTPRequest{}
+	for _, key := range gcpHTTPKeys {
+		field, ok := captured[key]
+		if !ok {
+			continue
+		}
+		if accessLog && request.accepts(key, field) {
+			request[key] = field
+			continue
+		}
+		field.AddTo(final.Encoder)
+	}
+	if len(request) > 0 {
+		if err := final.Encoder.AddObject(gcpHTTPRequestKey, request); err != nil {
+			return nil, err
+		}
+	}
+
+	if entry.Caller.Defined {
+		if err := final.Encoder.AddObject(gcpSourceLocationKey, gcpSourceLocation(entry.Caller)); err != nil {
+			return nil, err
+		}
+	}
+	if name := consoleLoggerName(entry.LoggerName); name != "" {
+		final.Encoder.AddString(gcpLoggerKey, name)
+	}
+	return final.Encoder.EncodeEntry(entry, nil)
+}
+
+// gcpSourceLocation is the LogEntrySourceLocation of a caller
+type gcpSourceLocation zapcore.EntryCaller
+
+func (c gcpSourceLocation) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("file", c.File)
+	// The line is a string, an int64 in the JSON mapping of the API
+	enc.AddString("line", strconv.Itoa(c.Line))
+	if c.Function != "" {
+		enc.AddString("function", c.Function)
+	}
+	return nil
+}
+
+// gcpHTTPRequest is the HttpRequest built from the HTTP middleware fields
+type gcpHTTPRequest map[string]zapcore.Field
+
+func (r gcpHTTPRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	r.addString(enc, "requestMethod", common.KeyHTTPMethod)
+	r.addString(enc, "requestUrl", common.KeyPathname)
+	if _, ok := r[common.KeyHTTPStatus]; ok {
+		status, _ := strconv.Atoi(r.value(common.KeyHTTPStatus))
+		enc.AddInt("status", status)
+	}
+	r.addString(enc, "responseSize", common.KeyHTTPBytes)
+	r.addString(enc, "userAgent", common.KeyHTTPUserAgent)
+	r.addString(enc, "remoteIp", common.KeyClientIP)
+	if _, ok := r[common.KeyHTTPLatencyMs]; ok {
+		ms, _ := strconv.ParseFloat(r.value(common.KeyHTTPLatencyMs), 64)
+		enc.AddString("latency", strconv.FormatFloat(ms/1000, 'f', -1, 64)+"s")
+	}
+	return nil
+}
+
+// accepts reports whether the value of field converts to the HttpRequest
+// member of key
+func (r gcpHTTPRequest) accepts(key string, field zapcore.Field) bool {
+	val, ok := topFieldValue(field)
+	if !ok {
+		return false
+	}
+	var err error
+	switch key {
+	case common.KeyHTTPStatus:
+		_, err = strconv.Atoi(val)
+	case common.KeyHTTPLatencyMs:
+		_, err = strconv.ParseFloat(val, 64)
+	}
+	return err == nil
+}
+
+func (r gcpHTTPRequest) value(key string) string {
+	field, ok := r[key]
+	if !ok {
+		return ""
+	}
+	val, _ := topFieldValue(field)
+	return val
+}
+
+func (r gcpHTTPRequest) addString(enc zapcore.ObjectEncoder, name, key string) {
+	if val := r.value(key); val != "" {
+		enc.AddString(name, val)
+	}
+}
@@ -0,0 +1,142 @@
+package zap
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestGCPEncoder_Entry(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithGCPEncoding("my-project"))
+	_, file, line, _ := runtime.Caller(0)
+	logger.Named("api").(*Logger).Warnw("slow", common.KeyTraceID, "4bf92f3577b34da6a3ce929d0e0e4736", common.KeySpanID, "00f067aa0ba902b7", "k", "v")
+
+	entry := readJSONEntry(t, logFile)
+	want := map[string]interface{}{
+		"severity":                      "WARNING",
+		"message":                       "slow",
+		"logger":                        "api",
+		"logging.googleapis.com/trace":  "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+		"logging.googleapis.com/spanId": "00f067aa0ba902b7",
+		"k":                             "v",
+	}
+	for key, val := range want {
+		if entry[key] != val {
+			t.Errorf("%s = %v, want %v", key, entry[key], val)
+		}
+	}
+	for _, key := range []string{"level", "msg", "ts", "caller", common.KeyTraceID, common.KeySpanID} {
+		if _, ok := entry[key]; ok {
+			t.Errorf("entry should not contain %q, got %v", key, entry)
+		}
+	}
+
+	if _, err := time.Parse(time.RFC3339Nano, entry["timestamp"].(string)); err != nil {
+		t.Errorf("timestamp should be RFC 3339, got %v", entry["timestamp"])
+	}
+	location, _ := entry["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+	if location["line"] != strconv.Itoa(line+1) || !strings.HasSuffix(file, location["file"].(string)) ||
+		!strings.HasSuffix(location["function"].(string), "TestGCPEncoder_Entry") {
+		t.Errorf("sourceLocation = %v, want the call site", location)
+	}
+}
+
+func TestGCPEncoder_Severity(t *testing.T) {
+	levels := map[common.Level]string{
+		common.DebugLevel: "DEBUG", common.InfoLevel: "INFO", common.WarnLevel: "WARNING",
+		common.ErrorLevel: "ERROR", common.DPanicLevel: "CRITICAL", common.PanicLevel: "ALERT", common.FatalLevel: "EMERGENCY",
+	}
+	for level, want := range levels {
+		if got := gcpSeverity(zapcore.Level(level)); got != want {
+			t.Errorf("gcpSeverity(%v) = %v, want %v", level, got, want)
+		}
+	}
+}
+
+func TestGCPEncoder_WithoutProject(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithGCPEncoding(""))
+	logger.With(common.KeyTraceID, "t-1", common.KeyPathname, "/users").Info("no project")
+
+	entry := readJSONEntry(t, logFile)
+	if entry[common.KeyTraceID] != "t-1" || entry["logging.googleapis.com/trace"] != nil {
+		t.Errorf("trace_id should stay a plain field without a project, got %v", entry)
+	}
+	if entry[common.KeyPathname] != "/users" || entry["httpRequest"] != nil {
+		t.Errorf("HTTP fields should stay plain fields outside access logs, got %v", entry)
+	}
+}
+
+func TestGCPEncoder_HTTPRequest(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithGCPEncoding("p"))
+	logger.With(common.KeyPathname, "/orders", common.KeyClientIP, "192.0.2.10").With(
+		common.KeyHTTPMethod, "POST", common.KeyHTTPStatus, 201, common.KeyHTTPBytes, 5,
+		common.KeyHTTPLatencyMs, int64(1500), common.KeyHTTPUserAgent, "test-agent", common.KeyAccessLog, true,
+	).Info("request completed")
+
+	entry := readJSONEntry(t, logFile)
+	want := map[string]interface{}{
+		"requestMethod": "POST", "requestUrl": "/orders", "status": float64(201), "responseSize": "5",
+		"userAgent": "test-agent", "remoteIp": "192.0.2.10", "latency": "1.5s",
+	}
+	if got := entry["httpRequest"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("httpRequest = %v, want %v", got, want)
+	}
+	for _, key := range []string{common.KeyHTTPMethod, common.KeyHTTPStatus, common.KeyPathname, common.KeyClientIP, common.KeyAccessLog} {
+		if _, ok := entry[key]; ok {
+			t.Errorf("%q should be moved into httpRequest, got %v", key, entry)
+		}
+	}
+}
+
+func TestGCPEncoder_HTTPRequestOnlyForAccessLogs(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithGCPEncoding("p"))
+	logger.Infow("order placed", common.KeyHTTPMethod, "card", common.KeyHTTPStatus, "paid")
+
+	entry := readJSONEntry(t, logFile)
+	if entry["httpRequest"] != nil || entry[common.KeyHTTPMethod] != "card" || entry[common.KeyHTTPStatus] != "paid" {
+		t.Errorf("generic method and status fields should stay plain fields, got %v", entry)
+	}
+}
+
+func TestGCPEncoder_HTTPRequestKeepsUnconvertedValues(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithGCPEncoding("p"))
+	logger.Infow("request completed", common.KeyAccessLog, true, common.KeyHTTPMethod, "GET",
+		common.KeyHTTPStatus, "teapot", common.KeyHTTPLatencyMs, "slow")
+
+	entry := readJSONEntry(t, logFile)
+	want := map[string]interface{}{"requestMethod": "GET"}
+	if got := entry["httpRequest"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("httpRequest = %v, want %v", got, want)
+	}
+	if entry[common.KeyHTTPStatus] != "teapot" || entry[common.KeyHTTPLatencyMs] != "slow" {
+		t.Errorf("values that do not convert should be written back, got %v", entry)
+	}
+}
+
+func TestGCPEncoder_CapturesWithFieldsOfAnyType(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithGCPEncoding("p"))
+	logger.With(
+		common.KeyHTTPStatus, int16(200), common.KeyHTTPBytes, uint16(5), common.KeyHTTPLatencyMs, 0.25,
+		common.KeyAccessLog, true,
+	).With(common.KeyHTTPMethod, []byte("GET")).Info("request completed")
+
+	entry := readJSONEntry(t, logFile)
+	want := map[string]interface{}{"status": float64(200), "responseSize": "5", "latency": "0.00025s"}
+	if got := entry["httpRequest"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("httpRequest = %v, want %v", got, want)
+	}
+	for _, key := range []string{common.KeyHTTPStatus, common.KeyHTTPBytes, common.KeyHTTPLatencyMs, common.KeyAccessLog} {
+		if _, ok := entry[key]; ok {
+			t.Errorf("%q should be moved into httpRequest, got %v", key, entry)
+		}
+	}
+	if entry[common.KeyHTTPMethod] != "R0VU" {
+		t.Errorf("a method that does not convert should be written back once, got %v", entry)
+	}
+}
@@ -0,0 +1,252 @@
+package zap
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// applyJSONOptions sets the entry keys and level encoder of opts on cfg
+func applyJSONOptions(cfg *zapcore.EncoderConfig, opts common.JSONOptions) {
+	cfg.TimeKey = jsonKey(opts.Keys.Time, cfg.TimeKey)
+	cfg.LevelKey = jsonKey(opts.Keys.Level, cfg.LevelKey)
+	cfg.NameKey = jsonKey(opts.Keys.Name, cfg.NameKey)
+	cfg.CallerKey = jsonKey(opts.Keys.Caller, cfg.CallerKey)
+	cfg.MessageKey = jsonKey(opts.Keys.Message, cfg.MessageKey)
+	cfg.StacktraceKey = jsonKey(opts.Keys.Stacktrace, cfg.StacktraceKey)
+
+	switch opts.LevelCase {
+	case common.LevelLower:
+		cfg.EncodeLevel = zapcore.LowercaseLevelEncoder
+	case common.LevelUpper:
+		cfg.EncodeLevel = zapcore.CapitalLevelEncoder
+	}
+}
+
+func jsonKey(key, def string) string {
+	switch key {
+	case "":
+		return def
+	case common.OmitKey:
+		return zapcore.OmitKey
+	}
+	return key
+}
+
+// jsonEncoderName returns the name of the encoder for opts: zap's JSON
+// encoder, or a registered nestedEncoder when user fields are nested
+func jsonEncoderName(opts common.JSONOptions) (string, error) {
+	if opts.FieldsKey == "" {
+		return common.EncodeJson, nil
+	}
+	return nestedEncoderName(common.EncodeJson, opts, zapcore.NewJSONEncoder, embedJSONFields)
+}
+
+// nestedEncoderName registers a nestedEncoder over the encoders of encoding
+// built by newEncoder and returns its name
+func nestedEncoderName(encoding string, opts common.JSONOptions,
+	newEncoder func(zapcore.EncoderConfig) zapcore.Encoder, embed embedFieldsFunc) (string, error) {
+	hoistKeys := opts.HoistKeys
+	if hoistKeys == nil {
+		hoistKeys = common.DefaultJSONHoistKeys
+	}
+	hoistKeys = append([]string{}, hoistKeys...)
+
+	name := fmt.Sprintf("nested-%s-%q-%q", encoding, opts.FieldsKey, hoistKeys)
+	err := registerEncoder(name, func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
+		return newNestedEncoder(cfg, newEncoder, embed, opts.FieldsKey, hoistKeys), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// embedFieldsFunc adds the user fields encoded by fields to root under key,
+// unless there are none
+type embedFieldsFunc func(root, fields zapcore.Encoder, key string) error
+
+// embedJSONFields embeds the object a JSON encoder built
+func embedJSONFields(root, fields zapcore.Encoder, key string) error {
+	nested, err := fields.EncodeEntry(zapcore.Entry{}, nil)
+	if err != nil {
+		return err
+	}
+	defer nested.Free()
+	if nested.Len() > len("{}") {
+		return root.AddReflected(key, json.RawMessage(nested.Bytes()))
+	}
+	return nil
+}
+
+// nestedEncoder writes user fields in an object under fieldsKey, except the
+// hoisted fields and registered top fields which stay at the root next to
+// the entry keys
+type nestedEncoder struct {
+	// Encoder writes the entry keys and hoisted fields
+	zapcore.Encoder
+	fields    zapcore.Encoder
+	embed     embedFieldsFunc
+	fieldsKey string
+	hoist     map[string]bool
+}
+
+func newNestedEncoder(cfg zapcore.EncoderConfig, newEncoder func(zapcore.EncoderConfig) zapcore.Encoder,
+	embed embedFieldsFunc, fieldsKey string, hoistKeys []string) *nestedEncoder {
+	fieldsCfg := cfg
+	fieldsCfg.TimeKey = zapcore.OmitKey
+	fieldsCfg.LevelKey = zapcore.OmitKey
+	fieldsCfg.NameKey = zapcore.OmitKey
+	fieldsCfg.CallerKey = zapcore.OmitKey
+	fieldsCfg.FunctionKey = zapcore.OmitKey
+	fieldsCfg.MessageKey = zapcore.OmitKey
+	fieldsCfg.StacktraceKey = zapcore.OmitKey
+	fieldsCfg.SkipLineEnding = true
+
+	hoist := make(map[string]bool, len(hoistKeys))
+	for _, key := range hoistKeys {
+		hoist[key] = true
+	}
+	return &nestedEncoder{
+		Encoder:   newEncoder(cfg),
+		fields:    newEncoder(fieldsCfg),
+		embed:     embed,
+		fieldsKey: fieldsKey,
+		hoist:     hoist,
+	}
+}
+
+// target returns the encoder of the field key
+func (enc *nestedEncoder) target(key string) zapcore.ObjectEncoder {
+	if enc.hoist[key] || common.IsTopField(key) {
+		return enc.Encoder
+	}
+	return enc.fields
+}
+
+func (enc *nestedEncoder) Clone() zapcore.Encoder {
+	return &nestedEncoder{
+		Encoder:   enc.Encoder.Clone(),
+		fields:    enc.fields.Clone(),
+		embed:     enc.embed,
+		fieldsKey: enc.fieldsKey,
+		hoist:     enc.hoist,
+	}
+}
+
+func (enc *nestedEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	root := enc.Clone().(*nestedEncoder)
+	for _, field := range fields {
+		field.AddTo(root)
+	}
+	if err := root.embed(root.Encoder, root.fields, enc.fieldsKey); err != nil {
+		return nil, err
+	}
+	return root.Encoder.EncodeEntry(entry, nil)
+}
+
+func (enc *nestedEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	return enc.target(key).AddArray(key, arr)
+}
+
+func (enc *nestedEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	return enc.target(key).AddObject(key, obj)
+}
+
+func (enc *nestedEncoder) AddBinary(key string, val []byte) {
+	enc.target(key).AddBinary(key, val)
+}
+
+func (enc *nestedEncoder) AddByteString(key string, val []byte) {
+	enc.target(key).AddByteString(key, val)
+}
+
+func (enc *nestedEncoder) AddBool(key string, val bool) {
+	enc.target(key).AddBool(key, val)
+}
+
+func (enc *nestedEncoder) AddComplex128(key string, val complex128) {
+	enc.target(key).AddComplex128(key, val)
+}
+
+func (enc *nestedEncoder) AddComplex64(key string, val complex64) {
+	enc.target(key).AddComplex64(key, val)
+}
+
+func (enc *nestedEncoder) AddDuration(key string, val time.Duration) {
+	enc.target(key).AddDuration(key, val)
+}
+
+func (enc *nestedEncoder) AddFloat64(key string, val float64) {
+	enc.target(key).AddFloat64(key, val)
+}
+
+func (enc *nestedEncoder) AddFloat32(key string, val float32) {
+	enc.target(key).AddFloat32(key, val)
+}
+
+func (enc *nestedEncoder) AddInt(key string, val int) {
+	enc.target(key).AddInt(key, val)
+}
+
+func (enc *nestedEncoder) AddInt64(key string, val int64) {
+	enc.target(key).AddInt64(key, val)
+}
+
+func (enc *nestedEncoder) AddInt32(key string, val int32) {
+	enc.target(key).AddInt32(key, val)
+}
+
+func (enc *nestedEncoder) AddInt16(key string, val int16) {
+	enc.target(key).AddInt16(key, val)
+}
+
+func (enc *nestedEncoder) AddInt8(key string, val int8) {
+	enc.target(key).AddInt8(key, val)
+}
+
+func (enc *nestedEncoder) AddString(key, val string) {
+	enc.target(key).AddString(key, val)
+}
+
+func (enc *nestedEncoder) AddTime(key string, val time.Time) {
+	enc.target(key).AddTime(key, val)
+}
+
+func (enc *nestedEncoder) AddUint(key string, val uint) {
+	enc.target(key).AddUint(key, val)
+}
+
+func (enc *nestedEncoder) AddUint64(key string, val uint64) {
+	enc.target(key).AddUint64(key, val)
+}
+
+func (enc *nestedEncoder) AddUint32(key string, val uint32) {
+	enc.target(key).AddUint32(key, val)
+}
+
+func (enc *nestedEncoder) AddUint16(key string, val uint16) {
+	enc.target(key).AddUint16(key, val)
+}
+
+func (enc *nestedEncoder) AddUint8(key string, val uint8) {
+	enc.target(key).AddUint8(key, val)
+}
+
+func (enc *nestedEncoder) AddUintptr(key string, val uintptr) {
+	enc.target(key).AddUintptr(key, val)
+}
+
+func (enc *nestedEncoder) AddReflected(key string, val interface{}) error {
+	return enc.target(key).AddReflected(key, val)
+}
+
+// OpenNamespace nests the following user fields, hoisted fields added later
+// still go to the root
+func (enc *nestedEncoder) OpenNamespace(key string) {
+	enc.fields.OpenNamespace(key)
+}
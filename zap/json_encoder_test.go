@@ -0,0 +1,96 @@
+package zap
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+)
+
+func TestJSONEncoder_DefaultKeys(t *testing.T) {
+	logger, logFile := newFileLogger(t)
+	logger.Infow("default", "k", "v")
+
+	entry := readJSONEntry(t, logFile)
+	for _, key := range []string{"ts", "level", "defaultLogger", "caller", "msg", "k"} {
+		if _, ok := entry[key]; !ok {
+			t.Errorf("entry should contain %q, got %v", key, entry)
+		}
+	}
+	if entry["level"] != "[info]" {
+		t.Errorf("level = %v, want [info]", entry["level"])
+	}
+}
+
+func TestJSONEncoder_KeysAndLevelCase(t *testing.T) {
+	logger, logFile := newFileLogger(t,
+		common.WithJSONKeys(common.JSONKeys{Time: "@timestamp", Message: "message", Name: common.OmitKey, Level: "severity"}),
+		common.WithJSONLevelCase(common.LevelUpper))
+	logger.Warn("renamed")
+
+	entry := readJSONEntry(t, logFile)
+	if entry["message"] != "renamed" || entry["severity"] != "WARN" || entry["@timestamp"] == nil {
+		t.Errorf("keys should be renamed, got %v", entry)
+	}
+	for _, key := range []string{"msg", "ts", "level", "defaultLogger"} {
+		if _, ok := entry[key]; ok {
+			t.Errorf("entry should not contain %q, got %v", key, entry)
+		}
+	}
+	if _, ok := entry["caller"]; !ok {
+		t.Errorf("keys left empty should keep their default, got %v", entry)
+	}
+
+	logger, logFile = newFileLogger(t, common.WithJSONLevelCase(common.LevelLower))
+	logger.Error("lower")
+	if level := readJSONEntry(t, logFile)["level"]; level != "error" {
+		t.Errorf("level = %v, want error", level)
+	}
+}
+
+func TestJSONEncoder_NestedFields(t *testing.T) {
+	common.RegisterTopField("tenant_id")
+	defer common.UnregisterTopField("tenant_id")
+
+	logger, logFile := newFileLogger(t, common.WithJSONFieldsKey("fields"), common.WithStacktraceLevel(common.ErrorLevel))
+	logger.WithFields(map[string]interface{}{
+		common.KeyTraceID: "t-1",
+		"tenant_id":       "acme",
+		"svc":             "api",
+	}).(*Logger).Errorw("nested", common.KeyUserID, 7, "attempt", 2, "error", errors.New("boom"))
+
+	entry := readJSONEntry(t, logFile)
+	if entry[common.KeyTraceID] != "t-1" || entry["tenant_id"] != "acme" || entry[common.KeyUserID] != float64(7) {
+		t.Errorf("trace_id, user_id and top fields should stay at the root, got %v", entry)
+	}
+	fields, ok := entry["fields"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("user fields should be nested, got %v", entry)
+	}
+	want := map[string]interface{}{"svc": "api", "attempt": float64(2), "error": "boom"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+	if _, ok := entry[common.KeyStacktrace].(string); !ok || entry["msg"] != "nested" {
+		t.Errorf("entry keys should stay at the root, got %v", entry)
+	}
+}
+
+func TestJSONEncoder_HoistKeys(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithJSONFieldsKey("data"), common.WithJSONHoistKeys("request_id"))
+	logger.Infow("hoisted", "request_id", "r-1", common.KeyTraceID, "t-1")
+	logger.Info("no fields")
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, logFile)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	if !strings.Contains(lines[0], `"request_id":"r-1","data":{"trace_id":"t-1"}`) {
+		t.Errorf("only the hoist keys should stay at the root, got %q", lines[0])
+	}
+	if strings.Contains(lines[1], `"data"`) {
+		t.Errorf("entries without user fields should not have an empty object, got %q", lines[1])
+	}
+}
@@ -0,0 +1,128 @@
+package zap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+)
+
+// Placeholders of a console layout with a meaning of their own. Any other
+// placeholder is a field slot.
+const (
+	layoutTime   = "time"
+	layoutLevel  = "level"
+	layoutName   = "name"
+	layoutCaller = "caller"
+	layoutMsg    = "msg"
+	layoutFields = "fields"
+)
+
+// layoutPart is a literal text or a placeholder of a console layout
+type layoutPart struct {
+	literal string
+	// key is the placeholder name, empty for literals
+	key string
+	// width pads the value to width runes, right aligned, or left aligned
+	// when negative
+	width int
+}
+
+// consoleLayout is a parsed console layout template
+type consoleLayout struct {
+	parts []layoutPart
+	// slots are the field keys rendered in a placeholder instead of the
+	// trailing field object
+	slots map[string]bool
+}
+
+// parseConsoleLayout parses a layout such as
+// "[{time}] [{level:-5}] {caller} [{trace_id}] {msg} {fields}". A
+// placeholder is {key} or {key:width}, and {{ and }} are literal braces.
+func parseConsoleLayout(layout string) (*consoleLayout, error) {
+	l := &consoleLayout{slots: map[string]bool{}}
+	var literal strings.Builder
+	for i := 0; i < len(layout); i++ {
+		c := layout[i]
+		switch {
+		case c == '{' && strings.HasPrefix(layout[i:], "{{"), c == '}' && strings.HasPrefix(layout[i:], "}}"):
+			literal.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(layout[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("console layout %q: unclosed placeholder at offset %d", layout, i)
+			}
+			part, err := parseLayoutPlaceholder(layout[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("console layout %q: %w", layout, err)
+			}
+			if literal.Len() > 0 {
+				l.parts = append(l.parts, layoutPart{literal: literal.String()})
+				literal.Reset()
+			}
+			l.parts = append(l.parts, part)
+			if !isLayoutBuiltin(part.key) {
+				l.slots[part.key] = true
+			}
+			i += end
+		case c == '}':
+			return nil, fmt.Errorf("console layout %q: unexpected } at offset %d", layout, i)
+		default:
+			literal.WriteByte(c)
+		}
+	}
+	if literal.Len() > 0 {
+		l.parts = append(l.parts, layoutPart{literal: literal.String()})
+	}
+	return l, nil
+}
+
+func parseLayoutPlaceholder(s string) (layoutPart, error) {
+	key, width, hasWidth := strings.Cut(s, ":")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return layoutPart{}, fmt.Errorf("empty placeholder {%s}", s)
+	}
+	part := layoutPart{key: key}
+	if hasWidth {
+		n, err := strconv.Atoi(strings.TrimSpace(width))
+		if err != nil {
+			return layoutPart{}, fmt.Errorf("invalid width in placeholder {%s}", s)
+		}
+		part.width = n
+	}
+	return part, nil
+}
+
+func isLayoutBuiltin(key string) bool {
+	switch key {
+	case layoutTime, layoutLevel, layoutName, layoutCaller, layoutMsg, layoutFields:
+		return true
+	}
+	return false
+}
+
+// appendPadded appends s padded with spaces to width runes
+func appendPadded(buf *buffer.Buffer, s string, width int) {
+	left := width < 0
+	if left {
+		width = -width
+	}
+	pad := width - utf8.RuneCountInString(s)
+	if !left {
+		appendSpaces(buf, pad)
+	}
+	buf.AppendString(s)
+	if left {
+		appendSpaces(buf, pad)
+	}
+}
+
+func appendSpaces(buf *buffer.Buffer, n int) {
+	for ; n > 0; n-- {
+		buf.AppendByte(' ')
+	}
+}
@@ -0,0 +1,128 @@
+package zap
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseConsoleLayout(t *testing.T) {
+	layout, err := parseConsoleLayout("{{[{time}]}} {level:-5}|{user_id:3} {msg}")
+	if err != nil {
+		t.Fatalf("parseConsoleLayout() error = %v", err)
+	}
+	want := []layoutPart{
+		{literal: "{["}, {key: "time"}, {literal: "]} "}, {key: "level", width: -5},
+		{literal: "|"}, {key: "user_id", width: 3}, {literal: " "}, {key: "msg"},
+	}
+	if len(layout.parts) != len(want) {
+		t.Fatalf("parts = %+v, want %+v", layout.parts, want)
+	}
+	for i := range want {
+		if layout.parts[i] != want[i] {
+			t.Errorf("parts[%d] = %+v, want %+v", i, layout.parts[i], want[i])
+		}
+	}
+	if len(layout.slots) != 1 || !layout.slots["user_id"] {
+		t.Errorf("slots = %v, want only user_id", layout.slots)
+	}
+
+	for _, invalid := range []string{"{msg", "{}", "{level:x}", "msg}"} {
+		if _, err := parseConsoleLayout(invalid); err == nil {
+			t.Errorf("parseConsoleLayout(%q) should fail", invalid)
+		}
+	}
+}
+
+func encodeWithLayout(t *testing.T, opts consoleOptions, with []zapcore.Field, entry zapcore.Entry, fields ...zapcore.Field) string {
+	enc := newCustomConsoleEncoder(newEncoderConfig(), opts)
+	if len(with) > 0 {
+		enc = enc.Clone()
+		for _, field := range with {
+			field.AddTo(enc)
+		}
+	}
+	buf, err := enc.EncodeEntry(entry, fields)
+	if err != nil {
+		t.Fatalf("EncodeEntry() error = %v", err)
+	}
+	defer buf.Free()
+	return buf.String()
+}
+
+func TestCustomConsoleEncoder_Layout(t *testing.T) {
+	opts := consoleOptions{
+		layout:     "{time} {level:-5} [{name}] {caller:15} [{trace_id}] [{user_id:4}] {msg} {fields}",
+		timeFormat: time.RFC3339,
+	}
+	entry := zapcore.Entry{
+		Level:      zapcore.InfoLevel,
+		Time:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		LoggerName: "-.api",
+		Caller:     zapcore.NewEntryCaller(0, "/src/app/main.go", 7, true),
+		Message:    "line\nforged",
+	}
+
+	got := encodeWithLayout(t, opts, []zapcore.Field{zap.String(common.KeyTraceID, "t-1"), zap.String("svc", "api")},
+		entry, zap.Int64(common.KeyUserID, 42), zap.Bool("ok", true))
+	want := `2024-01-02T03:04:05Z info  [api]   app/main.go:7 [t-1] [  42] line\nforged {"svc": "api", "ok": true}` + "\n"
+	if got != want {
+		t.Errorf("EncodeEntry() =\n%q\nwant\n%q", got, want)
+	}
+
+	entry.Caller = zapcore.EntryCaller{}
+	entry.Message = "no fields"
+	got = encodeWithLayout(t, opts, nil, entry)
+	want = `2024-01-02T03:04:05Z info  [api] ` + strings.Repeat(" ", 15) + ` [] [    ] no fields` + "\n"
+	if got != want {
+		t.Errorf("empty placeholders should render empty and trailing spaces be trimmed, got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestCustomConsoleEncoder_LayoutChoosesTopFields(t *testing.T) {
+	common.RegisterTopField("tenant_id")
+	defer common.UnregisterTopField("tenant_id")
+
+	entry := zapcore.Entry{Level: zapcore.WarnLevel, Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Message: "m"}
+	got := encodeWithLayout(t, consoleOptions{layout: "{level} {pathname} {msg} {fields}"}, nil, entry,
+		zap.String(common.KeyTraceID, "t-1"), zap.String("tenant_id", "acme"), zap.String(common.KeyPathname, "/users"))
+	want := `warn /users m {"trace_id": "t-1", "tenant_id": "acme"}` + "\n"
+	if got != want {
+		t.Errorf("fields without a placeholder should stay in the field object, got %q want %q", got, want)
+	}
+}
+
+func TestCustomConsoleEncoder_DefaultLayoutTimeFormat(t *testing.T) {
+	entry := zapcore.Entry{Level: zapcore.InfoLevel, Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Message: "m"}
+	got := encodeWithLayout(t, consoleOptions{timeFormat: common.TimeFormat}, nil, entry)
+	if want := "[2024-01-02 03:04:05] [info] [] m\n"; got != want {
+		t.Errorf("EncodeEntry() = %q, want %q", got, want)
+	}
+}
+
+func TestNewLogger_ConsoleLayout(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithConsoleEncoding(),
+		common.WithConsoleLayout("[{time}] [{level}] [{caller}] [{pathname}] [{trace_id}] {msg} {fields}"))
+	logger.Infow("request done", common.KeyTraceID, "t-1", common.KeyPathname, "/api/users", "status", 200)
+
+	// The format parsed by beats/filebeat/script_format1.js
+	format := regexp.MustCompile(`^\[(.*?)\] \[(.*?)\] \[(.*?)\] \[(.*?)\] \[(.*?)\] (.*)$`)
+	line := strings.TrimSpace(readFile(t, logFile))
+	match := format.FindStringSubmatch(line)
+	if match == nil {
+		t.Fatalf("line should match the filebeat format, got %q", line)
+	}
+	if match[2] != "info" || !strings.HasPrefix(match[3], "zap/layout_test.go:") || match[4] != "/api/users" ||
+		match[5] != "t-1" || match[6] != `request done {"status": 200}` {
+		t.Errorf("unexpected groups %q", match[1:])
+	}
+
+	if _, err := NewLogger(common.Options{}, common.WithOutputPath(logFile), common.WithConsoleLayout("{msg")); err == nil {
+		t.Error("NewLogger() should reject an invalid layout")
+	}
+}
@@ -0,0 +1,273 @@
+package zap
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// limiter applies common.Limits to entries
+type limiter struct {
+	limits common.Limits
+}
+
+// truncateString cuts s to max bytes on a rune boundary and appends the
+// truncation marker
+func truncateString(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + fmt.Sprintf(common.TruncatedBytesFormat, len(s)-cut)
+}
+
+// field applies the limits to a field at depth. It makes a limiter the
+// marshalFilter that applies them inside objects and arrays.
+func (l *limiter) field(field zapcore.Field, depth int) (zapcore.Field, bool) {
+	switch field.Type {
+	case zapcore.StringType:
+		field.String = truncateString(field.String, l.limits.MaxStringBytes)
+	case zapcore.ByteStringType, zapcore.BinaryType:
+		if b, ok := field.Interface.([]byte); ok && l.limits.MaxStringBytes > 0 && len(b) > l.limits.MaxStringBytes {
+			return zap.String(field.Key, truncateString(string(b), l.limits.MaxStringBytes)), true
+		}
+	case zapcore.StringerType:
+		if s, ok := field.Interface.(fmt.Stringer); ok && l.limits.MaxStringBytes > 0 {
+			if str := s.String(); len(str) > l.limits.MaxStringBytes {
+				return zap.String(field.Key, truncateString(str, l.limits.MaxStringBytes)), true
+			}
+		}
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok && l.limits.MaxStringBytes > 0 {
+			field.Interface = l.limitError(err)
+		}
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType:
+		if l.tooDeep(depth + 1) {
+			return zap.String(field.Key, common.TruncatedDepth), true
+		}
+	case zapcore.ReflectType:
+		field.Interface = l.limitValue(reflect.ValueOf(field.Interface), depth+1)
+	}
+	return field, true
+}
+
+func (l *limiter) element(v interface{}, depth int) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		return truncateString(v, l.limits.MaxStringBytes), true
+	case []byte:
+		if l.limits.MaxStringBytes > 0 && len(v) > l.limits.MaxStringBytes {
+			return truncateString(string(v), l.limits.MaxStringBytes), true
+		}
+		return v, true
+	case zapcore.ObjectMarshaler, zapcore.ArrayMarshaler:
+		if l.tooDeep(depth + 1) {
+			return common.TruncatedDepth, true
+		}
+		return v, true
+	}
+	return l.limitValue(reflect.ValueOf(v), depth+1), true
+}
+
+func (l *limiter) tooDeep(depth int) bool {
+	return l.limits.MaxDepth > 0 && depth > l.limits.MaxDepth
+}
+
+// limitError truncates the message and verbose form of an error field
+func (l *limiter) limitError(err error) error {
+	if nilError(err) {
+		return err
+	}
+	msg := err.Error()
+	verbose := ""
+	if _, ok := err.(fmt.Formatter); ok {
+		verbose = fmt.Sprintf("%+v", err)
+	}
+	if len(msg) <= l.limits.MaxStringBytes && len(verbose) <= l.limits.MaxStringBytes {
+		return err
+	}
+	return rewrittenError{
+		msg:     truncateString(msg, l.limits.MaxStringBytes),
+		verbose: truncateString(verbose, l.limits.MaxStringBytes),
+	}
+}
+
+// limitValue walks maps, slices and structs like the redactor does,
+// truncating strings, collections and nesting
+func (l *limiter) limitValue(v reflect.Value, depth int) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return truncateString(v.String(), l.limits.MaxStringBytes)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() || selfMarshaling(v.Type()) {
+			return v.Interface()
+		}
+		return l.limitValue(v.Elem(), depth)
+	}
+
+	nested := v.Kind() == reflect.Map || v.Kind() == reflect.Slice || v.Kind() == reflect.Array || v.Kind() == reflect.Struct
+	if !nested || selfMarshaling(v.Type()) {
+		return v.Interface()
+	}
+	if l.tooDeep(depth) {
+		return common.TruncatedDepth
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		keys := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			keys = append(keys, iter.Key().String())
+		}
+		sort.Strings(keys)
+		n := l.collectionLen(len(keys))
+		out := make(map[string]interface{}, n+1)
+		for _, key := range keys[:n] {
+			out[key] = l.limitValue(v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())), depth+1)
+		}
+		if n < len(keys) {
+			out["…"] = fmt.Sprintf(common.TruncatedElementsFormat, len(keys)-n)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.Kind() == reflect.Slice && l.limits.MaxStringBytes > 0 && v.Len() > l.limits.MaxStringBytes {
+				return truncateString(string(v.Bytes()), l.limits.MaxStringBytes)
+			}
+			return v.Interface()
+		}
+		n := l.collectionLen(v.Len())
+		out := make([]interface{}, 0, n+1)
+		for i := 0; i < n; i++ {
+			out = append(out, l.limitValue(v.Index(i), depth+1))
+		}
+		if n < v.Len() {
+			out = append(out, fmt.Sprintf(common.TruncatedElementsFormat, v.Len()-n))
+		}
+		return out
+	default:
+		out := make(map[string]interface{}, v.NumField())
+		l.limitStruct(out, v, depth)
+		return out
+	}
+}
+
+func (l *limiter) limitStruct(out map[string]interface{}, v reflect.Value, depth int) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, omitEmpty, skip := jsonFieldName(sf)
+		if skip {
+			continue
+		}
+		fv := v.Field(i)
+		if sf.Anonymous && name == "" && fv.Kind() == reflect.Struct && !selfMarshaling(fv.Type()) {
+			l.limitStruct(out, fv, depth)
+			continue
+		}
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		out[name] = l.limitValue(fv, depth+1)
+	}
+}
+
+func (l *limiter) collectionLen(n int) int {
+	if l.limits.MaxCollectionLen > 0 && n > l.limits.MaxCollectionLen {
+		return l.limits.MaxCollectionLen
+	}
+	return n
+}
+
+// limitFields limits each field and keeps at most room fields, trace_id and
+// the registered top fields aside, returning the number of fields counted
+// against room and the number dropped
+func (l *limiter) limitFields(fields []zapcore.Field, room int) ([]zapcore.Field, int, int) {
+	if len(fields) == 0 {
+		return fields, 0, 0
+	}
+	out := make([]zapcore.Field, 0, len(fields))
+	counted, dropped := 0, 0
+	for _, field := range fields {
+		if field.Type == zapcore.NamespaceType || field.Type == zapcore.SkipType {
+			out = append(out, field)
+			continue
+		}
+		// The context fields come from maps in no fixed order, the fields
+		// entries are looked up by are kept whatever their position
+		if field.Key != common.KeyTraceID && !common.IsTopField(field.Key) {
+			if room >= 0 && counted >= room {
+				dropped++
+				continue
+			}
+			counted++
+		}
+		field, _ = l.field(field, 0)
+		out = append(out, filterMarshaler(field, l, 0))
+	}
+	return out, counted, dropped
+}
+
+// limitTransform applies a limiter to the fields added with With and to
+// every entry. count and dropped track the fields added with With for the
+// field limit.
+type limitTransform struct {
+	limiter *limiter
+	count   int
+	dropped int
+}
+
+func newLimitCore(limits common.Limits) func(zapcore.Core) zapcore.Core {
+	return newWrapCore(&limitTransform{limiter: &limiter{limits: limits}})
+}
+
+// room returns how many more fields fit, or -1 without a field limit
+func (t *limitTransform) room() int {
+	if t.limiter.limits.MaxFields <= 0 {
+		return -1
+	}
+	if room := t.limiter.limits.MaxFields - t.count; room > 0 {
+		return room
+	}
+	return 0
+}
+
+func (t *limitTransform) with(fields []zapcore.Field) ([]zapcore.Field, coreTransform) {
+	limited, counted, dropped := t.limiter.limitFields(fields, t.room())
+	return limited, &limitTransform{
+		limiter: t.limiter,
+		count:   t.count + counted,
+		dropped: t.dropped + dropped,
+	}
+}
+
+func (t *limitTransform) entry(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	ent.Message = truncateString(ent.Message, t.limiter.limits.MaxMessageBytes)
+	limited, _, dropped := t.limiter.limitFields(fields, t.room())
+	if dropped += t.dropped; dropped > 0 {
+		limited = append(limited, zap.Int(common.KeyTruncatedFields, dropped))
+	}
+	return ent, limited
+}
@@ -0,0 +1,145 @@
+package zap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestTruncateString(t *testing.T) {
+	tests := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"abcdefgh", 3, "abc…(truncated 5 bytes)"},
+		{"日本語", 4, "日…(truncated 6 bytes)"},
+		{"unlimited", 0, "unlimited"},
+	}
+
+	for _, tt := range tests {
+		if got := truncateString(tt.in, tt.max); got != tt.want {
+			t.Errorf("truncateString(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestLimits_MessageAndStrings(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithMaxMessageBytes(8), common.WithMaxStringBytes(4))
+
+	logger.WithField("ctx", strings.Repeat("x", 100)).(*Logger).
+		Infow("a very long message", "body", "0123456789", "tags", []string{"abcdef", "ab"},
+			"nested", map[string]interface{}{"k": "long value"})
+
+	entry := readJSONEntry(t, logFile)
+	want := map[string]interface{}{
+		"msg":  "a very l…(truncated 11 bytes)",
+		"ctx":  "xxxx…(truncated 96 bytes)",
+		"body": "0123…(truncated 6 bytes)",
+	}
+	for key, val := range want {
+		if entry[key] != val {
+			t.Errorf("%s = %v, want %v", key, entry[key], val)
+		}
+	}
+	if tags := entry["tags"].([]interface{}); tags[0] != "abcd…(truncated 2 bytes)" || tags[1] != "ab" {
+		t.Errorf("typed slice elements should be truncated, got %v", tags)
+	}
+	if nested := entry["nested"].(map[string]interface{}); nested["k"] != "long…(truncated 6 bytes)" {
+		t.Errorf("nested strings should be truncated, got %v", nested)
+	}
+}
+
+func TestLimits_Collections(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithMaxCollectionLen(2), common.WithMaxDepth(1))
+
+	logger.Infow("collections",
+		"ids", []int{1, 2, 3, 4, 5},
+		"labels", map[string]string{"a": "1", "b": "2", "c": "3"},
+		"deep", map[string]interface{}{"l2": map[string]interface{}{"l3": "gone"}})
+
+	entry := readJSONEntry(t, logFile)
+	ids := entry["ids"].([]interface{})
+	if len(ids) != 3 || ids[2] != "…(truncated 3 elements)" {
+		t.Errorf("slices should keep 2 elements and a marker, got %v", ids)
+	}
+	labels := entry["labels"].(map[string]interface{})
+	if len(labels) != 3 || labels["a"] != "1" || labels["b"] != "2" || labels["…"] != "…(truncated 1 elements)" {
+		t.Errorf("maps should keep the first 2 keys and a marker, got %v", labels)
+	}
+	deep := entry["deep"].(map[string]interface{})
+	if deep["l2"] != common.TruncatedDepth {
+		t.Errorf("collections nested in a collection should be replaced at depth 1, got %v", deep)
+	}
+}
+
+func TestLimits_FieldCount(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithMaxFields(3))
+
+	logger.WithField("a", 1).WithField("b", 2).(*Logger).Infow("fields", "c", 3, "d", 4, "e", 5)
+
+	entry := readJSONEntry(t, logFile)
+	for _, key := range []string{"a", "b", "c"} {
+		if _, ok := entry[key]; !ok {
+			t.Errorf("%s should be kept, got %v", key, entry)
+		}
+	}
+	if _, ok := entry["d"]; ok {
+		t.Errorf("fields beyond the limit should be dropped, got %v", entry)
+	}
+	if entry[common.KeyTruncatedFields] != float64(2) {
+		t.Errorf("%s = %v, want 2", common.KeyTruncatedFields, entry[common.KeyTruncatedFields])
+	}
+}
+
+func TestLimits_RedactBeforeTruncate(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithMaxStringBytes(10), common.WithRedactPatterns(common.RedactEmailPattern))
+
+	logger.Infow("order", "contact", "bob.builder@example.com")
+
+	if entry := readJSONEntry(t, logFile); entry["contact"] != common.RedactedValue {
+		t.Errorf("values should be redacted before truncation, got %v", entry["contact"])
+	}
+}
+
+func TestLimits_MarshalersAndErrors(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithMaxStringBytes(4), common.WithMaxCollectionLen(2), common.WithMaxDepth(1))
+
+	logger.WithError(fmt.Errorf("wrapped: %w", errors.New("root cause"))).(*Logger).Desugar().Info("limited",
+		zap.Object("creds", credentials{user: "robert", tokens: []string{"a"}}),
+		zap.Array("groups", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			arr.AppendInt(1)
+			arr.AppendInt(2)
+			arr.AppendInt(3)
+			return nil
+		})),
+		zap.Array("nested", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			return arr.AppendObject(credentials{user: "bob"})
+		})),
+		zap.NamedError("plain", errors.New("long error")))
+
+	entry := readJSONEntry(t, logFile)
+	errObj := entry[common.KeyError].(map[string]interface{})
+	if errObj["msg"] != "wrap…(truncated 15 bytes)" || errObj["type"] != "*fmt…(truncated 10 bytes)" ||
+		errObj["…"] != "…(truncated 1 elements)" {
+		t.Errorf("error objects should be limited, got %v", errObj)
+	}
+	if creds := entry["creds"].(map[string]interface{}); len(creds) != 3 || creds["user"] != "robe…(truncated 2 bytes)" {
+		t.Errorf("marshaled objects should be limited, got %v", creds)
+	}
+	if groups := entry["groups"].([]interface{}); len(groups) != 3 || groups[2] != "…(truncated 1 elements)" {
+		t.Errorf("marshaled arrays should be limited, got %v", groups)
+	}
+	if nested := entry["nested"].([]interface{}); nested[0] != common.TruncatedDepth {
+		t.Errorf("objects nested in a marshaled array should be replaced at depth 1, got %v", nested)
+	}
+	if entry["plain"] != "long…(truncated 6 bytes)" {
+		t.Errorf("error fields should be truncated, got %v", entry["plain"])
+	}
+}
@@ -21,12 +21,28 @@ const (
 
 type Logger struct {
 	*zap.SugaredLogger
+	baggage common.BaggageOptions
+	spanID  bool
+}
+
+// derive returns a logger writing to su with the options of l
+func (l Logger) derive(su *zap.SugaredLogger) *Logger {
+	return &Logger{SugaredLogger: su, baggage: l.baggage, spanID: l.spanID}
+}
+
+// BaggageOptions returns the baggage whitelist of the logger
+func (l Logger) BaggageOptions() common.BaggageOptions {
+	return l.baggage
+}
+
+// LogsSpanID reports whether the span ID of the context is logged, only the
+// gcp encoding has a key for it
+func (l Logger) LogsSpanID() bool {
+	return l.spanID
 }
 
 func (l Logger) WithField(key string, value interface{}) common.Logger {
-	return &Logger{
-		SugaredLogger: l.SugaredLogger.With(zap.Any(key, value)),
-	}
+	return l.derive(l.SugaredLogger.With(zap.Any(key, value)))
 }
 
 func (l Logger) WithFields(fields map[string]interface{}) common.Logger {
@@ -34,9 +50,7 @@ func (l Logger) WithFields(fields map[string]interface{}) common.Logger {
 	for k, v := range fields {
 		args = append(args, k, v)
 	}
-	return &Logger{
-		SugaredLogger: l.SugaredLogger.With(args...),
-	}
+	return l.derive(l.SugaredLogger.With(args...))
 }
 
 func (l Logger) WithError(err error) common.Logger {
@@ -44,9 +58,7 @@ func (l Logger) WithError(err error) common.Logger {
 		return l
 	}
 
-	return &Logger{
-		l.SugaredLogger.With(zap.String("error", err.Error())),
-	}
+	return l.derive(l.SugaredLogger.With(zap.Object(common.KeyError, errorObject{err: err})))
 }
 
 func (l Logger) Warningf(format string, args ...interface{}) {
@@ -58,9 +70,24 @@ func (l Logger) Warning(args ...interface{}) {
 }
 
 func (l Logger) Named(name string) common.Logger {
-	return &Logger{
-		SugaredLogger: l.SugaredLogger.Named(name),
-	}
+	return l.derive(l.SugaredLogger.Named(name))
+}
+
+// AddCallerSkip returns a logger reporting the caller skip frames further up
+// the stack
+func (l Logger) AddCallerSkip(skip int) common.Logger {
+	return l.derive(l.SugaredLogger.WithOptions(zap.AddCallerSkip(skip)))
+}
+
+// WithStack returns a logger capturing a stack trace for every entry
+func (l Logger) WithStack() common.Logger {
+	return l.derive(l.SugaredLogger.WithOptions(zap.AddStacktrace(zapcore.DebugLevel)))
+}
+
+// WrapCore returns a logger whose zapcore.Core is wrapped by f, keeping the
+// accumulated fields, name and caller skip of l
+func (l Logger) WrapCore(f func(zapcore.Core) zapcore.Core) common.Logger {
+	return l.derive(l.SugaredLogger.Desugar().WithOptions(zap.WrapCore(f)).Sugar())
 }
 
 var (
@@ -95,7 +122,7 @@ func SetDefaultLoggerConfig(options common.Options, withFuncList ...common.WithF
 func init() {
 	// Register custom console encoder globally
 	err := zap.RegisterEncoder("custom-console", func(cfg zapcore.EncoderConfig) (zapcore.Encoder, error) {
-		return newCustomConsoleEncoder(cfg), nil
+		return newCustomConsoleEncoder(cfg, consoleOptions{}), nil
 	})
 	if err != nil {
 		fmt.Printf("WARNING: Failed to register custom console encoder: %v\n", err)
@@ -135,43 +162,55 @@ func NewLogger(options common.Options, withFuncs ...common.WithFunc) (*Logger, e
 		options.OutputPaths = append(options.OutputPaths, common.PathStdout)
 	}
 
-	if options.Encoding == "" {
-		options.Encoding = "custom-console"
-	} else if options.Encoding == common.EncodeConsole {
-		options.Encoding = "custom-console"
+	if options.Encoding == "" || options.Encoding == common.EncodeConsole {
+		pretty, color := prettyConsole(options)
+		timeFormat := consoleTimestampFormat(options)
+		name, err := consoleEncoderName(consoleOptions{
+			multilineStack: options.MultilineStacktrace,
+			layout:         options.ConsoleLayout,
+			timeFormat:     timeFormat.format,
+			timezone:       timeFormat.loc,
+			pretty:         pretty,
+			color:          color,
+		})
+		if err != nil {
+			return nil, err
+		}
+		options.Encoding = name
 	}
 
-	encodeCfg := zapcore.EncoderConfig{
-		TimeKey:       "ts",
-		LevelKey:      "level",
-		NameKey:       "defaultLogger",
-		CallerKey:     "caller",
-		FunctionKey:   zapcore.OmitKey,
-		MessageKey:    "msg",
-		StacktraceKey: "stacktrace",
-		LineEnding:    zapcore.DefaultLineEnding,
-		EncodeLevel: func(lv zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
-			enc.AppendString("[" + lv.String() + "]")
-		},
-		EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-			enc.AppendString("[" + t.Format(DateTimeFormat) + "]")
-		},
-		//EncodeDuration: func(duration time.Duration, encoder zapcore.PrimitiveArrayEncoder) {
-		//	encoder.AppendString(duration.String())
-		//},
-		EncodeCaller: func(call zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
-			trPath := call.TrimmedPath()
-			enc.AppendString(trPath)
-		},
-		EncodeName: func(name string, enc zapcore.PrimitiveArrayEncoder) {
-			names := strings.Split(name, ".")
-			if len(names) == 1 {
-				enc.AppendString("[]")
-				return
-			}
-			enc.AppendString("[" + strings.Join(names[1:], ".") + "]")
-		},
-		ConsoleSeparator: " ",
+	encodeCfg := newEncoderConfig()
+	encodeCfg.EncodeTime = jsonTimestampFormat(options, common.TimestampRFC3339Milli).encodeTime
+
+	if options.Encoding == common.EncodeJson {
+		applyJSONOptions(&encodeCfg, options.JSON)
+		name, err := jsonEncoderName(options.JSON)
+		if err != nil {
+			return nil, err
+		}
+		options.Encoding = name
+	}
+
+	spanID := options.Encoding == common.EncodeGCP
+	if spanID {
+		encodeCfg.EncodeTime = jsonTimestampFormat(options, common.TimestampRFC3339Nano).encodeTime
+		name, err := gcpEncoderName(options.GCPProjectID)
+		if err != nil {
+			return nil, err
+		}
+		options.Encoding = name
+	}
+
+	if _, ok := binaryFormats[options.Encoding]; ok {
+		// Binary records have the keys and nesting of the JSON encoding, with
+		// integer timestamps unless a format is set
+		applyJSONOptions(&encodeCfg, options.JSON)
+		encodeCfg.EncodeTime = jsonTimestampFormat(options, common.TimestampEpochNanos).encodeTime
+		name, err := binaryEncoderName(options.Encoding, options.JSON)
+		if err != nil {
+			return nil, err
+		}
+		options.Encoding = name
 	}
 
 	allLogPath := append(options.OutputPaths, options.ErrorOutputPaths...)
@@ -202,11 +241,63 @@ func NewLogger(options common.Options, withFuncs ...common.WithFunc) (*Logger, e
 		ErrorOutputPaths:  options.ErrorOutputPaths,
 	}
 
-	logger, err := cfg.Build(zap.AddCaller(), zap.AddCallerSkip(options.CallerSkip))
+	buildOptions := []zap.Option{zap.AddCaller(), zap.AddCallerSkip(options.CallerSkip)}
+	if options.Stacktrace {
+		buildOptions = append(buildOptions, zap.AddStacktrace(zapcore.Level(options.StacktraceLevel)))
+	}
+	// Redaction wraps the limits so values are matched before truncation
+	if options.Limits.Enabled() {
+		buildOptions = append(buildOptions, zap.WrapCore(newLimitCore(options.Limits)))
+	}
+	if options.Redact.Enabled {
+		buildOptions = append(buildOptions, zap.WrapCore(newRedactCore(options.Redact)))
+	}
+	if options.TrimStacktrace {
+		buildOptions = append(buildOptions, zap.WrapCore(newTrimStackCore))
+	}
+
+	logger, err := cfg.Build(buildOptions...)
 	if err != nil {
 		return nil, err
 	}
 
 	su := logger.Sugar().Named("-")
-	return &Logger{SugaredLogger: su}, nil
+	return &Logger{SugaredLogger: su, baggage: options.Baggage, spanID: spanID}, nil
+}
+
+// newEncoderConfig returns the encoder config shared by the console and JSON
+// encodings
+func newEncoderConfig() zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
+		TimeKey:       common.DefaultJSONTimeKey,
+		LevelKey:      common.DefaultJSONLevelKey,
+		NameKey:       common.DefaultJSONNameKey,
+		CallerKey:     common.DefaultJSONCallerKey,
+		FunctionKey:   zapcore.OmitKey,
+		MessageKey:    common.DefaultJSONMessageKey,
+		StacktraceKey: common.DefaultJSONStacktraceKey,
+		LineEnding:    zapcore.DefaultLineEnding,
+		EncodeLevel: func(lv zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString("[" + lv.String() + "]")
+		},
+		EncodeTime: func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString("[" + t.Format(DateTimeFormat) + "]")
+		},
+		//EncodeDuration: func(duration time.Duration, encoder zapcore.PrimitiveArrayEncoder) {
+		//	encoder.AppendString(duration.String())
+		//},
+		EncodeCaller: func(call zapcore.EntryCaller, enc zapcore.PrimitiveArrayEncoder) {
+			trPath := call.TrimmedPath()
+			enc.AppendString(trPath)
+		},
+		EncodeName: func(name string, enc zapcore.PrimitiveArrayEncoder) {
+			names := strings.Split(name, ".")
+			if len(names) == 1 {
+				enc.AppendString("[]")
+				return
+			}
+			enc.AppendString("[" + strings.Join(names[1:], ".") + "]")
+		},
+		ConsoleSeparator: " ",
+	}
 }
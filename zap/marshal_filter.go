@@ -0,0 +1,283 @@
+package zap
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// marshalFilter rewrites what ObjectMarshalers and ArrayMarshalers encode, so
+// rules applied to fields also reach inside them. depth is the nesting of
+// the object or array being encoded, top level fields are at depth 0.
+type marshalFilter interface {
+	// field rewrites a field an object adds, false drops it
+	field(field zapcore.Field, depth int) (zapcore.Field, bool)
+	// element rewrites a string, []byte, reflected value, ObjectMarshaler or
+	// ArrayMarshaler an array appends, false drops it
+	element(v interface{}, depth int) (interface{}, bool)
+	// collectionLen returns how many of the first n fields of an object or
+	// elements of an array are kept
+	collectionLen(n int) int
+}
+
+// filterMarshaler makes filter apply inside the marshaler of an object or
+// array field at depth
+func filterMarshaler(field zapcore.Field, filter marshalFilter, depth int) zapcore.Field {
+	switch m := field.Interface.(type) {
+	case zapcore.ObjectMarshaler:
+		if field.Type == zapcore.ObjectMarshalerType {
+			field.Interface = filteredObject{m: m, filter: filter, depth: depth + 1}
+		}
+	case zapcore.ArrayMarshaler:
+		if field.Type == zapcore.ArrayMarshalerType {
+			field.Interface = filteredArray{m: m, filter: filter, depth: depth + 1}
+		}
+	}
+	return field
+}
+
+type filteredObject struct {
+	m      zapcore.ObjectMarshaler
+	filter marshalFilter
+	depth  int
+}
+
+func (o filteredObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	filtered := &filterObjectEncoder{ObjectEncoder: enc, filter: o.filter, depth: o.depth}
+	err := o.m.MarshalLogObject(filtered)
+	if dropped := filtered.n - o.filter.collectionLen(filtered.n); dropped > 0 {
+		enc.AddString("…", fmt.Sprintf(common.TruncatedElementsFormat, dropped))
+	}
+	return err
+}
+
+type filteredArray struct {
+	m      zapcore.ArrayMarshaler
+	filter marshalFilter
+	depth  int
+}
+
+func (a filteredArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	filtered := &filterArrayEncoder{ArrayEncoder: enc, filter: a.filter, depth: a.depth}
+	err := a.m.MarshalLogArray(filtered)
+	if dropped := filtered.n - a.filter.collectionLen(filtered.n); dropped > 0 {
+		enc.AppendString(fmt.Sprintf(common.TruncatedElementsFormat, dropped))
+	}
+	return err
+}
+
+// filterObjectEncoder passes every field added to it through a filter. n
+// counts the fields added.
+type filterObjectEncoder struct {
+	zapcore.ObjectEncoder
+	filter marshalFilter
+	depth  int
+	n      int
+}
+
+func (e *filterObjectEncoder) add(field zapcore.Field) {
+	if e.n++; e.filter.collectionLen(e.n) < e.n {
+		return
+	}
+	if field, ok := e.filter.field(field, e.depth); ok {
+		filterMarshaler(field, e.filter, e.depth).AddTo(e.ObjectEncoder)
+	}
+}
+
+func (e *filterObjectEncoder) AddArray(key string, v zapcore.ArrayMarshaler) error {
+	e.add(zap.Array(key, v))
+	return nil
+}
+
+func (e *filterObjectEncoder) AddObject(key string, v zapcore.ObjectMarshaler) error {
+	e.add(zap.Object(key, v))
+	return nil
+}
+
+func (e *filterObjectEncoder) AddReflected(key string, v interface{}) error {
+	e.add(zap.Reflect(key, v))
+	return nil
+}
+
+func (e *filterObjectEncoder) AddBinary(key string, v []byte)          { e.add(zap.Binary(key, v)) }
+func (e *filterObjectEncoder) AddByteString(key string, v []byte)      { e.add(zap.ByteString(key, v)) }
+func (e *filterObjectEncoder) AddBool(key string, v bool)              { e.add(zap.Bool(key, v)) }
+func (e *filterObjectEncoder) AddComplex128(key string, v complex128)  { e.add(zap.Complex128(key, v)) }
+func (e *filterObjectEncoder) AddComplex64(key string, v complex64)    { e.add(zap.Complex64(key, v)) }
+func (e *filterObjectEncoder) AddDuration(key string, v time.Duration) { e.add(zap.Duration(key, v)) }
+func (e *filterObjectEncoder) AddFloat64(key string, v float64)        { e.add(zap.Float64(key, v)) }
+func (e *filterObjectEncoder) AddFloat32(key string, v float32)        { e.add(zap.Float32(key, v)) }
+func (e *filterObjectEncoder) AddInt(key string, v int)                { e.add(zap.Int(key, v)) }
+func (e *filterObjectEncoder) AddInt64(key string, v int64)            { e.add(zap.Int64(key, v)) }
+func (e *filterObjectEncoder) AddInt32(key string, v int32)            { e.add(zap.Int32(key, v)) }
+func (e *filterObjectEncoder) AddInt16(key string, v int16)            { e.add(zap.Int16(key, v)) }
+func (e *filterObjectEncoder) AddInt8(key string, v int8)              { e.add(zap.Int8(key, v)) }
+func (e *filterObjectEncoder) AddString(key, v string)                 { e.add(zap.String(key, v)) }
+func (e *filterObjectEncoder) AddTime(key string, v time.Time)         { e.add(zap.Time(key, v)) }
+func (e *filterObjectEncoder) AddUint(key string, v uint)              { e.add(zap.Uint(key, v)) }
+func (e *filterObjectEncoder) AddUint64(key string, v uint64)          { e.add(zap.Uint64(key, v)) }
+func (e *filterObjectEncoder) AddUint32(key string, v uint32)          { e.add(zap.Uint32(key, v)) }
+func (e *filterObjectEncoder) AddUint16(key string, v uint16)          { e.add(zap.Uint16(key, v)) }
+func (e *filterObjectEncoder) AddUint8(key string, v uint8)            { e.add(zap.Uint8(key, v)) }
+func (e *filterObjectEncoder) AddUintptr(key string, v uintptr)        { e.add(zap.Uintptr(key, v)) }
+
+// filterArrayEncoder passes the strings, bytes, reflected values, objects
+// and arrays appended to it through a filter. n counts the elements
+// appended.
+type filterArrayEncoder struct {
+	zapcore.ArrayEncoder
+	filter marshalFilter
+	depth  int
+	n      int
+}
+
+// keep counts an element and reports whether it fits the collection length
+func (e *filterArrayEncoder) keep() bool {
+	e.n++
+	return e.filter.collectionLen(e.n) == e.n
+}
+
+// append filters v and appends the result, reflected values are appended
+// with AppendReflected whatever their type
+func (e *filterArrayEncoder) append(v interface{}, reflected bool) error {
+	if !e.keep() {
+		return nil
+	}
+	v, ok := e.filter.element(v, e.depth)
+	if !ok {
+		return nil
+	}
+	if reflected {
+		return e.ArrayEncoder.AppendReflected(v)
+	}
+	switch v := v.(type) {
+	case string:
+		e.ArrayEncoder.AppendString(v)
+	case []byte:
+		e.ArrayEncoder.AppendByteString(v)
+	case zapcore.ObjectMarshaler:
+		return e.ArrayEncoder.AppendObject(filteredObject{m: v, filter: e.filter, depth: e.depth + 1})
+	case zapcore.ArrayMarshaler:
+		return e.ArrayEncoder.AppendArray(filteredArray{m: v, filter: e.filter, depth: e.depth + 1})
+	default:
+		return e.ArrayEncoder.AppendReflected(v)
+	}
+	return nil
+}
+
+func (e *filterArrayEncoder) AppendString(v string)                        { _ = e.append(v, false) }
+func (e *filterArrayEncoder) AppendByteString(v []byte)                    { _ = e.append(v, false) }
+func (e *filterArrayEncoder) AppendReflected(v interface{}) error          { return e.append(v, true) }
+func (e *filterArrayEncoder) AppendObject(v zapcore.ObjectMarshaler) error { return e.append(v, false) }
+func (e *filterArrayEncoder) AppendArray(v zapcore.ArrayMarshaler) error   { return e.append(v, false) }
+
+func (e *filterArrayEncoder) AppendBool(v bool) {
+	if e.keep() {
+		e.ArrayEncoder.AppendBool(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendComplex128(v complex128) {
+	if e.keep() {
+		e.ArrayEncoder.AppendComplex128(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendComplex64(v complex64) {
+	if e.keep() {
+		e.ArrayEncoder.AppendComplex64(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendDuration(v time.Duration) {
+	if e.keep() {
+		e.ArrayEncoder.AppendDuration(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendFloat64(v float64) {
+	if e.keep() {
+		e.ArrayEncoder.AppendFloat64(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendFloat32(v float32) {
+	if e.keep() {
+		e.ArrayEncoder.AppendFloat32(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendInt(v int) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendInt64(v int64) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt64(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendInt32(v int32) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt32(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendInt16(v int16) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt16(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendInt8(v int8) {
+	if e.keep() {
+		e.ArrayEncoder.AppendInt8(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendTime(v time.Time) {
+	if e.keep() {
+		e.ArrayEncoder.AppendTime(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendUint(v uint) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendUint64(v uint64) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint64(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendUint32(v uint32) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint32(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendUint16(v uint16) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint16(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendUint8(v uint8) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUint8(v)
+	}
+}
+
+func (e *filterArrayEncoder) AppendUintptr(v uintptr) {
+	if e.keep() {
+		e.ArrayEncoder.AppendUintptr(v)
+	}
+}
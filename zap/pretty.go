@@ -0,0 +1,173 @@
+package zap
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// ANSI escape sequences of the pretty console
+const (
+	colorReset   = "\x1b[0m"
+	colorDim     = "\x1b[2m"
+	colorRed     = "\x1b[31m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+)
+
+// Column widths of the pretty layout, values are padded so that messages
+// and the fields following them line up
+const (
+	prettyLevelWidth  = 5
+	prettyCallerWidth = 28
+	prettyMsgWidth    = 40
+)
+
+// prettyBlock is a multi-line field value printed below the entry
+type prettyBlock struct {
+	key string
+	val string
+}
+
+// prettyConsole resolves the pretty mode of options into whether the pretty
+// layout and colors are used
+func prettyConsole(options common.Options) (pretty, color bool) {
+	noColor := os.Getenv(common.EnvNoColor) != ""
+	switch options.Pretty {
+	case common.PrettyOn:
+		return true, !noColor
+	case common.PrettyAuto:
+		if noColor || !allTerminals(options.OutputPaths) {
+			return false, false
+		}
+		return true, true
+	}
+	return false, false
+}
+
+// allTerminals reports whether every output path is stdout or stderr
+// attached to a terminal
+func allTerminals(paths []string) bool {
+	for _, path := range paths {
+		var f *os.File
+		switch path {
+		case common.PathStdout:
+			f = os.Stdout
+		case common.PathStderr:
+			f = os.Stderr
+		default:
+			return false
+		}
+		info, err := f.Stat()
+		if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	return len(paths) > 0
+}
+
+func levelColor(level zapcore.Level) string {
+	switch {
+	case level <= zapcore.DebugLevel:
+		return colorMagenta
+	case level == zapcore.InfoLevel:
+		return colorBlue
+	case level == zapcore.WarnLevel:
+		return colorYellow
+	default:
+		return colorRed
+	}
+}
+
+// startColor and endColor wrap the following output in color when colors
+// are enabled
+func (enc *customConsoleEncoder) startColor(buf *buffer.Buffer, color string) {
+	if enc.opts.color {
+		buf.AppendString(color)
+	}
+}
+
+func (enc *customConsoleEncoder) endColor(buf *buffer.Buffer) {
+	if enc.opts.color {
+		buf.AppendString(colorReset)
+	}
+}
+
+// isBlock reports whether a string field is printed as a block below the
+// entry instead of escaped into the field object
+func (enc *customConsoleEncoder) isBlock(val string) bool {
+	return enc.opts.pretty && strings.ContainsRune(val, '\n')
+}
+
+// appendPretty appends the pretty layout,
+// time LEVEL caller [name] msg trace_id=... top_field=... {fields}
+func (enc *customConsoleEncoder) appendPretty(buf *buffer.Buffer, entry zapcore.Entry, slots map[string]string, fields []byte) {
+	line := bufferPool.Get()
+	defer line.Free()
+
+	enc.startColor(line, colorDim)
+	line.AppendString(enc.formatTime(entry.Time))
+	enc.endColor(line)
+	line.AppendString(" ")
+
+	enc.startColor(line, levelColor(entry.Level))
+	appendPadded(line, entry.Level.CapitalString(), -prettyLevelWidth)
+	enc.endColor(line)
+	line.AppendString(" ")
+
+	if entry.Caller.Defined {
+		enc.startColor(line, colorDim)
+		appendPadded(line, entry.Caller.TrimmedPath(), -prettyCallerWidth)
+		enc.endColor(line)
+		line.AppendString(" ")
+	}
+
+	if name := consoleLoggerName(entry.LoggerName); name != "" {
+		line.AppendString("[")
+		appendEscaped(line, name)
+		line.AppendString("] ")
+	}
+	appendPaddedEscaped(line, entry.Message, -prettyMsgWidth)
+
+	if traceID := slots[common.KeyTraceID]; traceID != "" {
+		line.AppendString(" ")
+		enc.startColor(line, colorCyan)
+		line.AppendString(common.KeyTraceID + "=")
+		appendEscaped(line, traceID)
+		enc.endColor(line)
+	}
+	for _, key := range common.TopFields() {
+		if val := slots[key]; val != "" {
+			line.AppendString(" ")
+			appendEscaped(line, key)
+			line.AppendString("=")
+			appendEscaped(line, val)
+		}
+	}
+
+	if len(fields) > len("{}") {
+		line.AppendString(" ")
+		appendSpacedObject(line, fields)
+	}
+	buf.Write(bytes.TrimRight(line.Bytes(), " "))
+}
+
+// appendBlocks appends multi-line field values as indented blocks, each
+// line escaped on its own
+func (enc *customConsoleEncoder) appendBlocks(buf *buffer.Buffer, blocks []prettyBlock) {
+	for _, block := range blocks {
+		buf.AppendString("\n    ")
+		appendEscaped(buf, block.key)
+		buf.AppendString(":")
+		for _, line := range strings.Split(strings.TrimRight(block.val, "\n"), "\n") {
+			buf.AppendString("\n        ")
+			appendEscaped(buf, line)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package zap
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestPrettyConsole_Mode(t *testing.T) {
+	files := common.Options{OutputPaths: []string{"app.log"}}
+	t.Setenv(common.EnvNoColor, "")
+
+	tests := []struct {
+		name          string
+		mode          common.PrettyMode
+		noColor       string
+		pretty, color bool
+	}{
+		{name: "off", mode: common.PrettyOff},
+		{name: "auto without terminal", mode: common.PrettyAuto},
+		{name: "on", mode: common.PrettyOn, pretty: true, color: true},
+		{name: "on with NO_COLOR", mode: common.PrettyOn, noColor: "1", pretty: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(common.EnvNoColor, tt.noColor)
+			options := files
+			common.WithPrettyConsole(tt.mode)(&options)
+			pretty, color := prettyConsole(options)
+			if pretty != tt.pretty || color != tt.color {
+				t.Errorf("prettyConsole() = %v, %v, want %v, %v", pretty, color, tt.pretty, tt.color)
+			}
+		})
+	}
+}
+
+func TestCustomConsoleEncoder_Pretty(t *testing.T) {
+	entry := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC),
+		LoggerName: "-.api",
+		Caller:     zapcore.NewEntryCaller(0, "/src/app/main.go", 7, true),
+		Message:    "slow request",
+		Stack:      "main.main\n\t/src/app/main.go:7",
+	}
+	got := encodeWithLayout(t, consoleOptions{pretty: true}, []zapcore.Field{zap.String(common.KeyTraceID, "t-1")}, entry,
+		zap.Int("status", 200), zap.String("body", "line 1\nline 2\n"))
+
+	want := "2024-01-02 03:04:05.006 WARN  app/main.go:7" + strings.Repeat(" ", 15) +
+		" [api] slow request" + strings.Repeat(" ", 28) + ` trace_id=t-1 {"status": 200}` +
+		"\n    body:\n        line 1\n        line 2" +
+		"\n    main.main\n    \t/src/app/main.go:7\n"
+	if got != want {
+		t.Errorf("EncodeEntry() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestCustomConsoleEncoder_PrettyColors(t *testing.T) {
+	entry := zapcore.Entry{Level: zapcore.ErrorLevel, Time: time.Now(), Message: "bad \x1b[2Jinput"}
+	got := encodeWithLayout(t, consoleOptions{pretty: true, color: true}, nil, entry, zap.String(common.KeyTraceID, "t-1"))
+
+	for _, want := range []string{colorRed + "ERROR" + colorReset, colorCyan + "trace_id=t-1" + colorReset, `bad \u001b[2Jinput`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("pretty line should contain %q, got %q", want, got)
+		}
+	}
+	if strings.Count(got, "\x1b[") != 6 {
+		t.Errorf("only time, level and trace_id should be colored, got %q", got)
+	}
+}
+
+func TestNewLogger_PrettyKeepsProductionFormat(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithConsoleEncoding(),
+		common.WithPrettyConsole(common.PrettyAuto))
+	logger.Infow("to file", "k", "line 1\nline 2")
+
+	content := readFile(t, logFile)
+	if strings.Contains(content, "\x1b[") || !strings.Contains(content, `{"k": "line 1\nline 2"}`) {
+		t.Errorf("auto mode should keep the plain layout for files, got %q", content)
+	}
+}
@@ -0,0 +1,363 @@
+package zap
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	redactTag      = "glog"
+	redactTagValue = "redact"
+	// maxRedactDepth bounds the walk into nested maps, slices and structs
+	maxRedactDepth = 10
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	// processRedactSalt is the salt of RedactHash when none is set, random so
+	// hashes of low entropy values can not be looked up, and shared by the
+	// loggers of the process so their hashes still correlate
+	processRedactSalt = sync.OnceValue(func() string {
+		var salt [16]byte
+		if _, err := rand.Read(salt[:]); err != nil {
+			return strconv.FormatInt(time.Now().UnixNano(), 16)
+		}
+		return hex.EncodeToString(salt[:])
+	})
+)
+
+// redactor applies common.RedactOptions to fields
+type redactor struct {
+	opts  common.RedactOptions
+	keys  []string
+	globs []string
+}
+
+func newRedactor(opts common.RedactOptions) *redactor {
+	if opts.Mode == common.RedactHash && opts.Salt == "" {
+		opts.Salt = processRedactSalt()
+	}
+	r := &redactor{opts: opts}
+	for _, key := range opts.Keys {
+		key = strings.ToLower(key)
+		if strings.ContainsAny(key, "*?[") {
+			r.globs = append(r.globs, key)
+		} else {
+			r.keys = append(r.keys, key)
+		}
+	}
+	return r
+}
+
+func (r *redactor) matchKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range r.keys {
+		if k == key {
+			return true
+		}
+	}
+	for _, glob := range r.globs {
+		if ok, _ := path.Match(glob, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// replacement returns the value replacing a redacted value, and false when
+// the value is dropped
+func (r *redactor) replacement(val string) (string, bool) {
+	switch r.opts.Mode {
+	case common.RedactDrop:
+		return "", false
+	case common.RedactHash:
+		sum := sha256.Sum256([]byte(r.opts.Salt + val))
+		return "sha256:" + hex.EncodeToString(sum[:8]), true
+	default:
+		return common.RedactedValue, true
+	}
+}
+
+// redactString applies the value patterns to s, and returns false when s
+// matched in drop mode
+func (r *redactor) redactString(s string) (string, bool) {
+	for _, pattern := range r.opts.Patterns {
+		if !pattern.MatchString(s) {
+			continue
+		}
+		if r.opts.Mode == common.RedactDrop {
+			return "", false
+		}
+		s = pattern.ReplaceAllStringFunc(s, func(match string) string {
+			val, _ := r.replacement(match)
+			return val
+		})
+	}
+	return s, true
+}
+
+// redactFields returns fields with the rules applied, leaving the given
+// slice untouched
+func (r *redactor) redactFields(fields []zapcore.Field) []zapcore.Field {
+	if len(fields) == 0 {
+		return fields
+	}
+	out := make([]zapcore.Field, 0, len(fields))
+	for _, field := range fields {
+		if field, ok := r.field(field, 0); ok {
+			out = append(out, filterMarshaler(field, r, 0))
+		}
+	}
+	return out
+}
+
+// field applies the rules to a field at depth. It makes a redactor the
+// marshalFilter that applies them inside objects and arrays.
+func (r *redactor) field(field zapcore.Field, depth int) (zapcore.Field, bool) {
+	if field.Type == zapcore.NamespaceType || field.Type == zapcore.SkipType {
+		return field, true
+	}
+	if r.matchKey(field.Key) {
+		val, ok := r.replacement(fieldString(field))
+		return zap.String(field.Key, val), ok
+	}
+
+	switch field.Type {
+	case zapcore.StringType:
+		val, ok := r.redactString(field.String)
+		field.String = val
+		return field, ok
+	case zapcore.ByteStringType:
+		if b, ok := field.Interface.([]byte); ok {
+			val, ok := r.redactString(string(b))
+			return zap.String(field.Key, val), ok
+		}
+	case zapcore.StringerType:
+		s, ok := field.Interface.(fmt.Stringer)
+		if !ok {
+			return field, true
+		}
+		val, ok := r.redactString(s.String())
+		return zap.String(field.Key, val), ok
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok {
+			return r.redactError(field, err)
+		}
+	case zapcore.ReflectType:
+		val, ok := r.redactValue(reflect.ValueOf(field.Interface), depth)
+		field.Interface = val
+		return field, ok
+	}
+	return field, true
+}
+
+func (r *redactor) element(v interface{}, depth int) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		return r.redactString(v)
+	case []byte:
+		return r.redactString(string(v))
+	case zapcore.ObjectMarshaler, zapcore.ArrayMarshaler:
+		return v, true
+	}
+	return r.redactValue(reflect.ValueOf(v), depth)
+}
+
+func (r *redactor) collectionLen(n int) int {
+	return n
+}
+
+// redactError applies the patterns to the message and the verbose form of
+// an error field, dropping the field when either matches in drop mode
+func (r *redactor) redactError(field zapcore.Field, err error) (zapcore.Field, bool) {
+	if nilError(err) {
+		return field, true
+	}
+	msg, ok := r.redactString(err.Error())
+	if !ok {
+		return field, false
+	}
+	redacted := rewrittenError{msg: msg}
+	if _, isFormatter := err.(fmt.Formatter); isFormatter {
+		if redacted.verbose, ok = r.redactString(fmt.Sprintf("%+v", err)); !ok {
+			return field, false
+		}
+	}
+	field.Interface = redacted
+	return field, true
+}
+
+// redactValue walks maps, slices and structs, applying the key rules to map
+// keys and struct field names, the value patterns to strings and the
+// `glog:"redact"` tag to struct fields. Structs are converted to maps keyed
+// like encoding/json, values marshalling themselves are left untouched and
+// values past maxRedactDepth are replaced whole.
+func (r *redactor) redactValue(v reflect.Value, depth int) (interface{}, bool) {
+	if !v.IsValid() {
+		return nil, true
+	}
+	if depth >= maxRedactDepth {
+		// Values nested too deeply to walk are not logged as is
+		return r.replacement(valueString(v))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return r.redactString(v.String())
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return v.Interface(), true
+		}
+		if selfMarshaling(v.Type()) {
+			return v.Interface(), true
+		}
+		return r.redactValue(v.Elem(), depth+1)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || selfMarshaling(v.Type()) {
+			return v.Interface(), true
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			r.setRedacted(out, iter.Key().String(), iter.Value(), false, depth)
+		}
+		return out, true
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 || selfMarshaling(v.Type()) {
+			return v.Interface(), true
+		}
+		out := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if val, ok := r.redactValue(v.Index(i), depth+1); ok {
+				out = append(out, val)
+			}
+		}
+		return out, true
+	case reflect.Struct:
+		if selfMarshaling(v.Type()) {
+			return v.Interface(), true
+		}
+		out := make(map[string]interface{}, v.NumField())
+		r.redactStruct(out, v, depth)
+		return out, true
+	}
+	return v.Interface(), true
+}
+
+func (r *redactor) redactStruct(out map[string]interface{}, v reflect.Value, depth int) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, omitEmpty, skip := jsonFieldName(sf)
+		if skip {
+			continue
+		}
+		fv := v.Field(i)
+		if sf.Anonymous && name == "" && fv.Kind() == reflect.Struct && !selfMarshaling(fv.Type()) {
+			r.redactStruct(out, fv, depth+1)
+			continue
+		}
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		r.setRedacted(out, name, fv, sf.Tag.Get(redactTag) == redactTagValue, depth)
+	}
+}
+
+// setRedacted stores the redacted v under key in out, unless it is dropped
+func (r *redactor) setRedacted(out map[string]interface{}, key string, v reflect.Value, tagged bool, depth int) {
+	if tagged || r.matchKey(key) {
+		if val, ok := r.replacement(valueString(v)); ok {
+			out[key] = val
+		}
+		return
+	}
+	if val, ok := r.redactValue(v, depth+1); ok {
+		out[key] = val
+	}
+}
+
+func jsonFieldName(sf reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return parts[0], omitEmpty, false
+}
+
+func selfMarshaling(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType)
+}
+
+func valueString(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	if v.CanInterface() {
+		return fmt.Sprint(v.Interface())
+	}
+	return ""
+}
+
+// fieldString renders a field value as the input of a hash
+func fieldString(field zapcore.Field) string {
+	switch field.Type {
+	case zapcore.StringType:
+		return field.String
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return strconv.FormatInt(field.Integer, 10)
+	case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
+		return strconv.FormatUint(uint64(field.Integer), 10)
+	case zapcore.BoolType:
+		return strconv.FormatBool(field.Integer == 1)
+	}
+	if field.Interface != nil {
+		return fmt.Sprint(field.Interface)
+	}
+	return field.String
+}
+
+func newRedactCore(opts common.RedactOptions) func(zapcore.Core) zapcore.Core {
+	return newWrapCore(newRedactor(opts))
+}
+
+// with and entry make a redactor the coreTransform of a wrapCore, redacting
+// the fields added with With and the fields of every entry
+func (r *redactor) with(fields []zapcore.Field) ([]zapcore.Field, coreTransform) {
+	return r.redactFields(fields), r
+}
+
+func (r *redactor) entry(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	return ent, r.redactFields(fields)
+}
@@ -0,0 +1,217 @@
+package zap
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type loginRequest struct {
+	User     string    `json:"user"`
+	Password string    `json:"password"`
+	PIN      string    `json:"pin" glog:"redact"`
+	Note     string    `json:"note,omitempty"`
+	Internal string    `json:"-"`
+	At       time.Time `json:"at"`
+}
+
+func TestRedact_Keys(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithRedaction())
+
+	logger.WithFields(map[string]interface{}{
+		"password":      "hunter2",
+		"Authorization": "Bearer abc",
+		"refresh_token": "r-123",
+		"user":          "bob",
+	}).(*Logger).Infow("login", "body", map[string]interface{}{"secret": "s", "nested": map[string]interface{}{"api_key": 42}})
+
+	entry := readJSONEntry(t, logFile)
+	for _, key := range []string{"password", "Authorization", "refresh_token"} {
+		if entry[key] != common.RedactedValue {
+			t.Errorf("%s should be masked, got %v", key, entry[key])
+		}
+	}
+	if entry["user"] != "bob" {
+		t.Errorf("user should be kept, got %v", entry["user"])
+	}
+	body := entry["body"].(map[string]interface{})
+	if body["secret"] != common.RedactedValue || body["nested"].(map[string]interface{})["api_key"] != common.RedactedValue {
+		t.Errorf("nested keys should be masked, got %v", body)
+	}
+}
+
+func TestRedact_Patterns(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithRedactPatterns(common.RedactCreditCardPattern, common.RedactEmailPattern))
+
+	logger.Infow("payment", "card", "paid with 4111 1111 1111 1111", "contact", "bob@example.com",
+		"items", []interface{}{"a@b.io", "ok"}, "created_ms", "1760000000000")
+
+	entry := readJSONEntry(t, logFile)
+	if entry["card"] != "paid with ***" || entry["contact"] != "***" {
+		t.Errorf("matched values should be masked, got %v and %v", entry["card"], entry["contact"])
+	}
+	if items := entry["items"].([]interface{}); items[0] != "***" || items[1] != "ok" {
+		t.Errorf("slice elements should be masked, got %v", items)
+	}
+	if entry["created_ms"] != "1760000000000" {
+		t.Errorf("timestamps should not match the card pattern, got %v", entry["created_ms"])
+	}
+}
+
+func TestRedact_StructTags(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithRedactKeys("password"))
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	logger.WithField("req", &loginRequest{User: "bob", Password: "p", PIN: "1234", Internal: "x", At: at}).Info("login")
+
+	req := readJSONEntry(t, logFile)["req"].(map[string]interface{})
+	if req["user"] != "bob" || req["password"] != "***" || req["pin"] != "***" {
+		t.Errorf("tagged and matching struct fields should be masked, got %v", req)
+	}
+	if _, ok := req["note"]; ok {
+		t.Errorf("omitempty fields should be omitted, got %v", req)
+	}
+	if _, ok := req["Internal"]; ok {
+		t.Errorf(`json:"-" fields should be skipped, got %v`, req)
+	}
+	if req["at"] != "2024-01-02T03:04:05Z" {
+		t.Errorf("values marshalling themselves should be kept, got %v", req["at"])
+	}
+}
+
+func TestRedact_DropAndHash(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithRedactKeys("password"), common.WithRedactMode(common.RedactDrop))
+	logger.Infow("drop", "password", "p", "user", "bob")
+	entry := readJSONEntry(t, logFile)
+	if _, ok := entry["password"]; ok || entry["user"] != "bob" {
+		t.Errorf("password should be dropped, got %v", entry)
+	}
+
+	hashed := func(salt string) interface{} {
+		logger, logFile := newFileLogger(t, common.WithRedactKeys("email"), common.WithRedactMode(common.RedactHash),
+			common.WithRedactSalt(salt))
+		logger.Infow("hash", "email", "bob@example.com")
+		return readJSONEntry(t, logFile)["email"]
+	}
+	first, again, salted := hashed("s1"), hashed("s1"), hashed("s2")
+	if s, _ := first.(string); !regexp.MustCompile(`^sha256:[0-9a-f]{16}$`).MatchString(s) {
+		t.Errorf("hashed value should be a sha256 prefix, got %v", first)
+	}
+	if first != again || first == salted {
+		t.Errorf("hashes should be stable per salt, got %v, %v and %v", first, again, salted)
+	}
+}
+
+func TestRedact_HashWithoutSalt(t *testing.T) {
+	hashed := func() interface{} {
+		logger, logFile := newFileLogger(t, common.WithRedactKeys("pin"), common.WithRedactMode(common.RedactHash))
+		logger.Infow("hash", "pin", "1234")
+		return readJSONEntry(t, logFile)["pin"]
+	}
+	first, again := hashed(), hashed()
+
+	unsalted := sha256.Sum256([]byte("1234"))
+	if first == "sha256:"+hex.EncodeToString(unsalted[:8]) {
+		t.Errorf("values should not be hashed without a salt, got %v", first)
+	}
+	if first != again {
+		t.Errorf("loggers of a process should share the generated salt, got %v and %v", first, again)
+	}
+}
+
+func TestRedact_Disabled(t *testing.T) {
+	logger, logFile := newFileLogger(t)
+	logger.Infow("plain", "password", "visible")
+	if entry := readJSONEntry(t, logFile); entry["password"] != "visible" {
+		t.Errorf("fields should not be redacted by default, got %v", entry)
+	}
+}
+
+func TestRedact_Errors(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithRedactKeys("session"),
+		common.WithRedactPatterns(regexp.MustCompile(`token=\w+`)))
+
+	cause := &fieldsError{msg: "auth failed token=abc123", fields: map[string]interface{}{"session": "s-1", "user": "bob"}}
+	logger.WithError(fmt.Errorf("login: %w", cause)).(*Logger).Desugar().Error("failed",
+		zap.NamedError("retry", errors.New("retry token=def456")))
+
+	raw := readFile(t, logFile)
+	for _, secret := range []string{"abc123", "def456", "s-1"} {
+		if strings.Contains(raw, secret) {
+			t.Errorf("%s should be redacted, got %s", secret, raw)
+		}
+	}
+	entry := readJSONEntry(t, logFile)
+	errObj := entry[common.KeyError].(map[string]interface{})
+	if errObj["msg"] != "login: auth failed ***" {
+		t.Errorf("error message should be masked, got %v", errObj["msg"])
+	}
+	if causes := errObj["causes"].([]interface{}); causes[0].(map[string]interface{})["msg"] != "auth failed ***" {
+		t.Errorf("causes should be masked, got %v", causes)
+	}
+	if fields := errObj["fields"].(map[string]interface{}); fields["session"] != "***" || fields["user"] != "bob" {
+		t.Errorf("LogFields() should be redacted, got %v", fields)
+	}
+	if entry["retry"] != "retry ***" {
+		t.Errorf("error fields should be masked, got %v", entry["retry"])
+	}
+}
+
+type credentials struct {
+	user, password string
+	tokens         []string
+}
+
+func (c credentials) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("user", c.user)
+	enc.AddString("password", c.password)
+	return enc.AddArray("tokens", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, token := range c.tokens {
+			arr.AppendString(token)
+		}
+		return nil
+	}))
+}
+
+func TestRedact_Marshalers(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithRedactKeys("password"),
+		common.WithRedactPatterns(regexp.MustCompile(`tok-\w+`)))
+
+	logger.Desugar().Info("login", zap.Object("creds", credentials{user: "bob", password: "p", tokens: []string{"tok-1", "ok"}}),
+		zap.Strings("ids", []string{"tok-2"}))
+
+	entry := readJSONEntry(t, logFile)
+	creds := entry["creds"].(map[string]interface{})
+	if creds["user"] != "bob" || creds["password"] != "***" {
+		t.Errorf("object fields should be redacted, got %v", creds)
+	}
+	if tokens := creds["tokens"].([]interface{}); tokens[0] != "***" || tokens[1] != "ok" {
+		t.Errorf("nested array elements should be masked, got %v", tokens)
+	}
+	if ids := entry["ids"].([]interface{}); ids[0] != "***" {
+		t.Errorf("array elements should be masked, got %v", ids)
+	}
+}
+
+func TestRedact_MaxDepth(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithRedactKeys("password"))
+
+	var nested interface{} = map[string]interface{}{"password": "p", "note": "kept"}
+	for i := 0; i < maxRedactDepth; i++ {
+		nested = []interface{}{nested}
+	}
+	logger.Infow("deep", "nested", nested)
+
+	if raw := readFile(t, logFile); strings.Contains(raw, "kept") || !strings.Contains(raw, `"***"`) {
+		t.Errorf("values past the walk depth should be masked, got %s", raw)
+	}
+}
@@ -0,0 +1,69 @@
+package zap
+
+import (
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// trimmedStackPrefixes are the functions dropped from trimmed stack traces
+var trimmedStackPrefixes = []string{"runtime.", "github.com/gw123/glog.", "github.com/gw123/glog/"}
+
+// trimStackTransform drops runtime and glog frames from the stack of
+// entries before they are encoded
+type trimStackTransform struct{}
+
+func newTrimStackCore(core zapcore.Core) zapcore.Core {
+	return newWrapCore(trimStackTransform{})(core)
+}
+
+func (t trimStackTransform) with(fields []zapcore.Field) ([]zapcore.Field, coreTransform) {
+	return fields, t
+}
+
+func (trimStackTransform) entry(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	ent.Stack = trimStack(ent.Stack)
+	return ent, fields
+}
+
+// trimStack drops the trimmed frames from a stack formatted by zap as
+// function and tab indented file:line line pairs. Frames in test files are
+// kept.
+func trimStack(stack string) string {
+	if stack == "" {
+		return stack
+	}
+	lines := strings.Split(stack, "\n")
+	kept := lines[:0]
+	for i := 0; i < len(lines); i += 2 {
+		function := lines[i]
+		location := ""
+		if i+1 < len(lines) {
+			location = lines[i+1]
+		}
+		if trimmedFrame(function, location) {
+			continue
+		}
+		kept = append(kept, function)
+		if location != "" {
+			kept = append(kept, location)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+func trimmedFrame(function, location string) bool {
+	file := strings.TrimSpace(location)
+	if i := strings.LastIndexByte(file, ':'); i >= 0 {
+		file = file[:i]
+	}
+	if strings.HasSuffix(file, "_test.go") {
+		return false
+	}
+	for _, prefix := range trimmedStackPrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
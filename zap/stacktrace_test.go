@@ -0,0 +1,103 @@
+package zap
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gw123/glog/common"
+)
+
+func TestStacktraceLevel_Console(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithConsoleEncoding(),
+		common.WithStacktraceLevel(common.ErrorLevel), common.WithMultilineStacktrace())
+
+	logger.Warn("no stack")
+	logger.Error("with stack")
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, logFile)), "\n")
+	if !strings.Contains(lines[0], "no stack") || !strings.Contains(lines[1], "with stack") {
+		t.Fatalf("unexpected output %v", lines)
+	}
+	if len(lines) < 4 {
+		t.Fatalf("error entry should be followed by a stack block, got %v", lines)
+	}
+	if !strings.HasPrefix(lines[2], "    ") || !strings.Contains(lines[2], "TestStacktraceLevel_Console") {
+		t.Errorf("stack should start with the indented caller frame, got %q", lines[2])
+	}
+	if !strings.HasPrefix(lines[3], "    \t") || !strings.Contains(lines[3], "stacktrace_test.go:") {
+		t.Errorf("stack frame location should be indented, got %q", lines[3])
+	}
+}
+
+func TestStacktrace_ConsoleSingleLine(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithConsoleEncoding(), common.WithStacktraceLevel(common.ErrorLevel))
+
+	logger.Errorw("with stack", "k", "v")
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, logFile)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("the stack should stay on the entry line by default, got %v", lines)
+	}
+	if !strings.Contains(lines[0], `{"k": "v", "stacktrace": "github.com/gw123/glog/zap.TestStacktrace_ConsoleSingleLine\n\t`) {
+		t.Errorf("the stack should be an escaped field, got %q", lines[0])
+	}
+}
+
+func TestWithStack_JSON(t *testing.T) {
+	logger, logFile := newFileLogger(t)
+
+	logger.Info("plain")
+	common.AddStack(logger).Info("per call")
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, logFile)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %v", len(lines), lines)
+	}
+	var plain, withStack map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &plain); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[0], err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &withStack); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[1], err)
+	}
+	if _, ok := plain[common.KeyStacktrace]; ok {
+		t.Errorf("entries without WithStack() should have no stack, got %q", lines[0])
+	}
+	stack, _ := withStack[common.KeyStacktrace].(string)
+	if !strings.Contains(stack, "TestWithStack_JSON") {
+		t.Errorf("WithStack() should add the stack as a field, got %q", lines[1])
+	}
+}
+
+func TestStacktraceTrim(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithStacktraceLevel(common.ErrorLevel), common.WithStacktraceTrim())
+
+	logger.WithField("k", "v").Error("trimmed")
+
+	stack, _ := readJSONEntry(t, logFile)[common.KeyStacktrace].(string)
+	if !strings.Contains(stack, "TestStacktraceTrim") {
+		t.Errorf("frames in test files should be kept, got %q", stack)
+	}
+	if strings.Contains(stack, "runtime.goexit") {
+		t.Errorf("runtime frames should be trimmed, got %q", stack)
+	}
+}
+
+func TestTrimStack(t *testing.T) {
+	stack := strings.Join([]string{
+		"github.com/gw123/glog.ExtractEntry",
+		"\t/src/glog/ctxlog.go:10",
+		"main.handler",
+		"\t/src/app/main.go:20",
+		"github.com/gw123/glog/middleware/http.New.func1",
+		"\t/src/glog/middleware/http/middleware.go:30",
+		"runtime.goexit",
+		"\t/usr/local/go/src/runtime/asm_amd64.s:1650",
+	}, "\n")
+
+	want := "main.handler\n\t/src/app/main.go:20"
+	if got := trimStack(stack); got != want {
+		t.Errorf("trimStack() = %q, want %q", got, want)
+	}
+}
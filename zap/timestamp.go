@@ -0,0 +1,98 @@
+package zap
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gw123/glog/common"
+	"go.uber.org/zap/zapcore"
+)
+
+// rfc3339Milli is RFC 3339 with millisecond precision
+const rfc3339Milli = "2006-01-02T15:04:05.000Z07:00"
+
+// timestampFormat renders entry times in a format of
+// common.WithTimestampFormat and a timezone, shared by all encodings
+type timestampFormat struct {
+	// format is a named format or a layout, the encoding default when empty
+	format string
+	// loc converts times before formatting, nil keeps them as they are
+	loc *time.Location
+}
+
+// epoch returns the time as an epoch integer for the epoch formats
+func (f timestampFormat) epoch(t time.Time) (int64, bool) {
+	switch f.format {
+	case common.TimestampEpochSeconds:
+		return t.Unix(), true
+	case common.TimestampEpochMillis:
+		return t.UnixMilli(), true
+	case common.TimestampEpochNanos:
+		return t.UnixNano(), true
+	}
+	return 0, false
+}
+
+// layout returns the time.Time.Format layout of the format, def when the
+// format is empty
+func (f timestampFormat) layout(def string) string {
+	switch f.format {
+	case "":
+		return def
+	case common.TimestampRFC3339:
+		return time.RFC3339
+	case common.TimestampRFC3339Milli:
+		return rfc3339Milli
+	case common.TimestampRFC3339Nano:
+		return time.RFC3339Nano
+	}
+	return f.format
+}
+
+// String formats t, with DateTimeFormat by default
+func (f timestampFormat) String(t time.Time) string {
+	if f.loc != nil {
+		t = t.In(f.loc)
+	}
+	if n, ok := f.epoch(t); ok {
+		return strconv.FormatInt(n, 10)
+	}
+	return t.Format(f.layout(DateTimeFormat))
+}
+
+// encodeTime is a zapcore.TimeEncoder appending epoch formats as integers
+func (f timestampFormat) encodeTime(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+	if f.loc != nil {
+		t = t.In(f.loc)
+	}
+	if n, ok := f.epoch(t); ok {
+		enc.AppendInt64(n)
+		return
+	}
+	enc.AppendString(t.Format(f.layout(DateTimeFormat)))
+}
+
+// jsonTimestampFormat returns the time format of structured encodings, def
+// unless a format is set, in UTC unless a timezone is set. def must carry
+// the timezone, as UTC is not the local time of most readers.
+func jsonTimestampFormat(options common.Options, def string) timestampFormat {
+	format := options.TimestampFormat
+	if format == "" {
+		format = def
+	}
+	loc := options.Timezone
+	if loc == nil {
+		loc = time.UTC
+	}
+	return timestampFormat{format: format, loc: loc}
+}
+
+// consoleTimestampFormat returns the time format of the console encoding,
+// in local time unless a timezone is set
+func consoleTimestampFormat(options common.Options) timestampFormat {
+	format := options.TimestampFormat
+	if options.ConsoleTimeFormat != "" {
+		format = options.ConsoleTimeFormat
+	}
+	return timestampFormat{format: format, loc: options.Timezone}
+}
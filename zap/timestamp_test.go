@@ -0,0 +1,79 @@
+package zap
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gw123/glog/common"
+)
+
+func TestTimestampFormat_String(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	shanghai := time.FixedZone("CST", 8*3600)
+
+	tests := []struct {
+		format timestampFormat
+		want   string
+	}{
+		{timestampFormat{}, "2024-01-02 03:04:05.123"},
+		{timestampFormat{loc: shanghai}, "2024-01-02 11:04:05.123"},
+		{timestampFormat{format: common.TimestampRFC3339}, "2024-01-02T03:04:05Z"},
+		{timestampFormat{format: common.TimestampRFC3339Milli, loc: shanghai}, "2024-01-02T11:04:05.123+08:00"},
+		{timestampFormat{format: common.TimestampRFC3339Nano}, "2024-01-02T03:04:05.123456789Z"},
+		{timestampFormat{format: common.TimestampEpochSeconds}, "1704164645"},
+		{timestampFormat{format: common.TimestampEpochMillis, loc: shanghai}, "1704164645123"},
+		{timestampFormat{format: common.TimestampEpochNanos}, "1704164645123456789"},
+		{timestampFormat{format: "02/01 15:04"}, "02/01 03:04"},
+	}
+	for _, tt := range tests {
+		if got := tt.format.String(ts); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func logJSONTime(t *testing.T, withFuncs ...common.WithFunc) interface{} {
+	logger, logFile := newFileLogger(t, withFuncs...)
+	logger.Info("time")
+
+	return readJSONEntry(t, logFile)["ts"]
+}
+
+func TestNewLogger_JSONTimestamp(t *testing.T) {
+	before := time.Now()
+
+	def, ok := logJSONTime(t).(string)
+	if !ok || !strings.HasSuffix(def, "Z") {
+		t.Fatalf("default JSON time should be a UTC string, got %v", def)
+	}
+	parsed, err := time.Parse(rfc3339Milli, def)
+	if err != nil || parsed.Before(before.Add(-time.Second)) || parsed.After(time.Now().Add(time.Second)) {
+		t.Errorf("default JSON time should be RFC 3339 with milliseconds, got %v (%v)", def, err)
+	}
+
+	millis, ok := logJSONTime(t, common.WithTimestampFormat(common.TimestampEpochMillis)).(float64)
+	if !ok || int64(millis) < before.UnixMilli() {
+		t.Errorf("epoch millis should be a number, got %v", millis)
+	}
+
+	rfc, _ := logJSONTime(t, common.WithTimestampFormat(common.TimestampRFC3339Nano), common.WithTimezone(time.FixedZone("", 8*3600))).(string)
+	if _, err := time.Parse(time.RFC3339Nano, rfc); err != nil || !strings.HasSuffix(rfc, "+08:00") {
+		t.Errorf("want RFC 3339 in +08:00, got %q (%v)", rfc, err)
+	}
+}
+
+func TestNewLogger_ConsoleTimestamp(t *testing.T) {
+	logger, logFile := newFileLogger(t, common.WithConsoleEncoding(),
+		common.WithTimestampFormat(common.TimestampRFC3339Milli), common.WithTimezone(time.UTC))
+	logger.Info("time")
+
+	line := readFile(t, logFile)
+	end := strings.IndexByte(line, ']')
+	if !strings.HasPrefix(line, "[") || end < 0 {
+		t.Fatalf("unexpected line %q", line)
+	}
+	if _, err := time.Parse(rfc3339Milli, line[1:end]); err != nil || !strings.HasSuffix(line[1:end], "Z") {
+		t.Errorf("console time should be RFC 3339 in UTC, got %q (%v)", line[1:end], err)
+	}
+}
@@ -0,0 +1,69 @@
+package zap
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// coreTransform rewrites what a wrapCore passes to the core it wraps
+type coreTransform interface {
+	// with rewrites the fields added with With and returns the transform of
+	// the derived core
+	with(fields []zapcore.Field) ([]zapcore.Field, coreTransform)
+	// entry rewrites an entry and its fields before they are written
+	entry(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field)
+}
+
+// wrapCore applies a transform to the entries written to the core it wraps
+type wrapCore struct {
+	zapcore.Core
+	transform coreTransform
+}
+
+func newWrapCore(transform coreTransform) func(zapcore.Core) zapcore.Core {
+	return func(core zapcore.Core) zapcore.Core {
+		return &wrapCore{Core: core, transform: transform}
+	}
+}
+
+func (c *wrapCore) With(fields []zapcore.Field) zapcore.Core {
+	fields, transform := c.transform.with(fields)
+	return &wrapCore{Core: c.Core.With(fields), transform: transform}
+}
+
+// Check keeps the decision of the wrapped core, so sampling applies and
+// only the sub-cores of a tee that accept the entry get it, and writes the
+// transformed entry to them
+func (c *wrapCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	checked := c.Core.Check(ent, nil)
+	if checked == nil {
+		return ce
+	}
+	written := &checkedCore{wrapCore: c, checked: checked}
+	ce = ce.AddCore(ent, written)
+	written.logged = ce
+	return ce
+}
+
+func (c *wrapCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent, fields = c.transform.entry(ent, fields)
+	return c.Core.Write(ent, fields)
+}
+
+// checkedCore writes an entry to the cores the wrapped core of a wrapCore
+// checked it for
+type checkedCore struct {
+	*wrapCore
+	checked *zapcore.CheckedEntry
+	// logged is the entry of the logger checked was added to
+	logged *zapcore.CheckedEntry
+}
+
+func (c *checkedCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	// The logger adds the caller and stack after checking
+	c.checked.Entry, fields = c.transform.entry(ent, fields)
+	// Write combines the errors of the cores with multierr, reports them to
+	// the error output of the logger and returns checked to its pool
+	c.checked.ErrorOutput = c.logged.ErrorOutput
+	c.checked.Write(fields...)
+	return nil
+}
@@ -0,0 +1,76 @@
+package zap
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// upperTransform upper cases messages
+type upperTransform struct{}
+
+func (t upperTransform) with(fields []zapcore.Field) ([]zapcore.Field, coreTransform) {
+	return fields, t
+}
+
+func (upperTransform) entry(ent zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	ent.Message = strings.ToUpper(ent.Message)
+	return ent, fields
+}
+
+func TestWrapCore_KeepsTeeDecision(t *testing.T) {
+	infoCore, infoLogs := observer.New(zapcore.InfoLevel)
+	errorCore, errorLogs := observer.New(zapcore.ErrorLevel)
+	logger := zap.New(newWrapCore(upperTransform{})(zapcore.NewTee(infoCore, errorCore)), zap.AddCaller())
+
+	logger.Info("info")
+	logger.Error("error")
+
+	if got := infoLogs.All(); len(got) != 2 || got[0].Message != "INFO" || got[1].Message != "ERROR" {
+		t.Errorf("info core got %v, want both transformed entries", got)
+	}
+	if got := errorLogs.All(); len(got) != 1 || got[0].Message != "ERROR" || !got[0].Caller.Defined {
+		t.Errorf("error core got %v, want only the error entry with its caller", got)
+	}
+	if logger.Debug("debug"); infoLogs.Len() != 2 {
+		t.Errorf("entries no sub-core accepts should not be written")
+	}
+}
+
+// failingCore fails every write with err
+type failingCore struct {
+	zapcore.Core
+	err string
+}
+
+func (failingCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c failingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
+
+func (c failingCore) Write(zapcore.Entry, []zapcore.Field) error {
+	return errors.New(c.err)
+}
+
+func TestWrapCore_WriteErrors(t *testing.T) {
+	var errOut bytes.Buffer
+	tee := zapcore.NewTee(failingCore{zapcore.NewNopCore(), "disk full"}, failingCore{zapcore.NewNopCore(), "socket closed"})
+	// Nested like the limit, redaction and stack trimming cores
+	core := newWrapCore(upperTransform{})(newWrapCore(upperTransform{})(tee))
+	logger := zap.New(core, zap.ErrorOutput(zapcore.AddSync(&errOut)))
+
+	logger.Info("lost")
+
+	out := errOut.String()
+	if strings.Count(out, "write error") != 1 || !strings.Contains(out, "write error: disk full; socket closed") {
+		t.Errorf("error output = %q, want the errors of both cores reported once", out)
+	}
+}